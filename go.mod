@@ -0,0 +1,3 @@
+module github.com/YaremaTymchyshyn/go-coding-4
+
+go 1.21
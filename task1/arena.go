@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/YaremaTymchyshyn/go-coding-4/task1/memsim"
+)
+
+var arenas = make(map[string]*memsim.Heap)
+var cur *memsim.Heap
+var curName string
+
+// defaultConfig holds the startup flags that new arenas inherit.
+var defaultConfig memsim.Config
+
+func createArena(name string, cfg memsim.Config) error {
+	if _, ok := arenas[name]; ok {
+		return fmt.Errorf("arena %q already exists", name)
+	}
+	h, err := memsim.NewHeap(cfg)
+	if err != nil {
+		return err
+	}
+	if h.Size() != cfg.Size {
+		fmt.Println("Buddy mode: memory size rounded to", h.Size())
+	}
+	arenas[name] = h
+	return nil
+}
+
+func useArena(name string) bool {
+	h, ok := arenas[name]
+	if !ok {
+		return false
+	}
+	cur, curName = h, name
+	return true
+}
+
+func arenaNames() []string {
+	names := make([]string, 0, len(arenas))
+	for name := range arenas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func runArenaCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Please provide an arena command: create, use or list.")
+		return
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 3 {
+			fmt.Println("Please provide the arena name and memory size.")
+			return
+		}
+		size, err := strconv.Atoi(args[2])
+		if err != nil || size <= 0 {
+			fmt.Println("Invalid memory size.")
+			return
+		}
+		cfg := defaultConfig
+		cfg.Size = size
+		if len(args) > 3 {
+			cfg.Strategy = args[3]
+		}
+		if err := createArena(args[1], cfg); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Arena %s created.\n", args[1])
+	case "use":
+		if len(args) < 2 {
+			fmt.Println("Please provide the arena name.")
+			return
+		}
+		if !useArena(args[1]) {
+			fmt.Printf("Unknown arena %q.\n", args[1])
+			return
+		}
+		fmt.Println("Using arena", curName)
+	case "list":
+		for _, name := range arenaNames() {
+			marker := " "
+			if name == curName {
+				marker = "*"
+			}
+			h := arenas[name]
+			fmt.Printf("%s %s: %d cells, %s mode, %s strategy, %d blocks\n",
+				marker, name, h.Size(), h.Mode(), h.Strategy(), len(h.Blocks()))
+		}
+	default:
+		fmt.Println("Unknown arena command. Use create, use or list.")
+	}
+}
+
+func printLeakReports() {
+	for _, name := range arenaNames() {
+		h := arenas[name]
+		if h.Mode() == "paging" {
+			continue
+		}
+		if len(arenas) > 1 {
+			fmt.Printf("Arena %s: ", name)
+		}
+		printLeakReport(h)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/YaremaTymchyshyn/go-coding-4/task1/memsim"
+)
+
+var distNames = []string{"uniform", "exponential", "bimodal"}
+
+type benchConfig struct {
+	mem        int
+	ops        int
+	seed       int64
+	freeShare  float64
+	min, max   int
+	mean       float64
+	small      int
+	large      int
+	largeShare float64
+}
+
+// benchOp is either an allocation of size cells or, when size is 0, a free of
+// the live block picked by pick, a number in [0, 1). Picking by fraction keeps
+// the workload identical for every strategy even though their live sets differ.
+type benchOp struct {
+	size int
+	pick float64
+}
+
+func runBench(args []string) {
+	var cfg benchConfig
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	fs.IntVar(&cfg.mem, "mem", 4096, "memory size in cells")
+	fs.IntVar(&cfg.ops, "ops", 10000, "number of allocate/free operations")
+	fs.Int64Var(&cfg.seed, "seed", 1, "random seed")
+	fs.Float64Var(&cfg.freeShare, "free", 0.4, "share of operations that free a live block")
+	dist := fs.String("dist", "all", "size distribution: "+strings.Join(distNames, ", ")+" or all")
+	fs.IntVar(&cfg.min, "min", 1, "smallest size for the uniform distribution")
+	fs.IntVar(&cfg.max, "max", 64, "largest size for the uniform distribution")
+	fs.Float64Var(&cfg.mean, "mean", 16, "mean size for the exponential distribution")
+	fs.IntVar(&cfg.small, "small", 8, "largest small size for the bimodal distribution")
+	fs.IntVar(&cfg.large, "large", 256, "largest large size for the bimodal distribution")
+	fs.Float64Var(&cfg.largeShare, "large-share", 0.1, "share of large requests for the bimodal distribution")
+	fs.Parse(args)
+
+	if cfg.mem <= 0 || cfg.ops <= 0 || cfg.min <= 0 || cfg.max < cfg.min || cfg.mean < 1 || cfg.small <= 0 || cfg.large <= 0 {
+		fmt.Println("Invalid benchmark parameters.")
+		return
+	}
+
+	dists := distNames
+	if *dist != "all" {
+		dists = []string{*dist}
+	}
+	for i, name := range dists {
+		workload, err := generateWorkload(name, cfg)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Workload: %s, %d ops, %d cells, seed %d\n", name, cfg.ops, cfg.mem, cfg.seed)
+		fmt.Println("strategy  allocs  failed  fail rate  avg frag  final frag  ns/op")
+		for _, strategy := range memsim.Strategies {
+			r := runWorkload(strategy, cfg.mem, workload)
+			fmt.Printf("%-9s %-7d %-7d %-10s %-9.3f %-11.3f %d\n", strategy, r.allocs, r.failed,
+				fmt.Sprintf("%.2f%%", r.failRate()), r.avgFrag, r.finalFrag, r.nsPerOp)
+		}
+	}
+}
+
+func generateWorkload(dist string, cfg benchConfig) ([]benchOp, error) {
+	rng := rand.New(rand.NewSource(cfg.seed))
+	var size func() int
+	switch dist {
+	case "uniform":
+		size = func() int { return cfg.min + rng.Intn(cfg.max-cfg.min+1) }
+	case "exponential":
+		size = func() int { return 1 + int(rng.ExpFloat64()*(cfg.mean-1)) }
+	case "bimodal":
+		size = func() int {
+			if rng.Float64() < cfg.largeShare {
+				return cfg.large/2 + rng.Intn(cfg.large-cfg.large/2) + 1
+			}
+			return 1 + rng.Intn(cfg.small)
+		}
+	default:
+		return nil, fmt.Errorf("unknown distribution %q (available: %s)", dist, strings.Join(distNames, ", "))
+	}
+
+	ops := make([]benchOp, cfg.ops)
+	for i := range ops {
+		if rng.Float64() < cfg.freeShare {
+			ops[i].pick = rng.Float64()
+		} else {
+			ops[i].size = min(size(), cfg.mem)
+		}
+	}
+	return ops, nil
+}
+
+type benchResult struct {
+	allocs    int
+	failed    int
+	avgFrag   float64
+	finalFrag float64
+	nsPerOp   int64
+}
+
+func (r benchResult) failRate() float64 {
+	if r.allocs == 0 {
+		return 0
+	}
+	return 100 * float64(r.failed) / float64(r.allocs)
+}
+
+func runWorkload(strategy string, mem int, workload []benchOp) benchResult {
+	h, _ := memsim.NewHeap(memsim.Config{Mode: "contiguous", Strategy: strategy, Size: mem, Width: mem})
+	var r benchResult
+	var live []int
+	var elapsed time.Duration
+	fragSum := 0.0
+
+	for _, op := range workload {
+		if op.size > 0 {
+			r.allocs++
+			start := time.Now()
+			id, err := h.Allocate(op.size, "", 1)
+			elapsed += time.Since(start)
+			if err != nil {
+				r.failed++
+			} else {
+				live = append(live, id)
+			}
+		} else if len(live) > 0 {
+			i := int(op.pick * float64(len(live)))
+			start := time.Now()
+			h.Free(live[i])
+			elapsed += time.Since(start)
+			live[i] = live[len(live)-1]
+			live = live[:len(live)-1]
+		}
+		fragSum += fragmentation(h)
+	}
+
+	r.avgFrag = fragSum / float64(len(workload))
+	r.finalFrag = fragmentation(h)
+	r.nsPerOp = elapsed.Nanoseconds() / int64(len(workload))
+	return r
+}
+
+// fragmentation is the same measure as Stats reports, computed from the free
+// totals alone so that sampling it after every operation stays cheap.
+func fragmentation(h *memsim.Heap) float64 {
+	if h.FreeCells() == 0 {
+		return 0
+	}
+	return 1 - float64(h.LargestHole())/float64(h.FreeCells())
+}
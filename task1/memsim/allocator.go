@@ -0,0 +1,97 @@
+package memsim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// allocator is a placement strategy. Find returns the first cell of the hole
+// a request of numCells goes into, or -1 when none is large enough.
+type allocator interface {
+	Name() string
+	Find(idx *freeIndex, numCells int) int
+}
+
+type hole struct {
+	start, size int
+}
+
+var Strategies = []string{"first", "best", "worst", "next"}
+
+func newAllocator(name string) (allocator, error) {
+	switch name {
+	case "first":
+		return firstFit{}, nil
+	case "best":
+		return bestFit{}, nil
+	case "worst":
+		return worstFit{}, nil
+	case "next":
+		return &nextFit{}, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q (available: %s)", name, strings.Join(Strategies, ", "))
+}
+
+// sameAllocator reports whether switching from a to b would change nothing,
+// including where next fit resumes.
+func sameAllocator(a, b allocator) bool {
+	if x, ok := a.(*nextFit); ok {
+		y, ok := b.(*nextFit)
+		return ok && x.last == y.last
+	}
+	return a == b
+}
+
+type firstFit struct{}
+
+func (firstFit) Name() string { return "first" }
+
+func (firstFit) Find(idx *freeIndex, numCells int) int {
+	if n := idx.firstFrom(0, numCells); n != nil {
+		return n.start
+	}
+	return -1
+}
+
+type bestFit struct{}
+
+func (bestFit) Name() string { return "best" }
+
+func (bestFit) Find(idx *freeIndex, numCells int) int {
+	if n := idx.smallestFit(numCells); n != nil {
+		return n.start
+	}
+	return -1
+}
+
+type worstFit struct{}
+
+func (worstFit) Name() string { return "worst" }
+
+func (worstFit) Find(idx *freeIndex, numCells int) int {
+	largest := idx.largest()
+	if largest < numCells {
+		return -1
+	}
+	return idx.firstFrom(0, largest).start
+}
+
+// nextFit resumes the search where the previous allocation ended and wraps
+// around to the beginning of memory once it reaches the end.
+type nextFit struct {
+	last int
+}
+
+func (a *nextFit) Name() string { return "next" }
+
+func (a *nextFit) Find(idx *freeIndex, numCells int) int {
+	start := -1
+	if h := idx.byStart.floor(a.last); h != nil && h.start+h.size-a.last >= numCells {
+		start = a.last
+	} else if n := idx.firstFrom(a.last, numCells); n != nil {
+		start = n.start
+	} else if n := idx.firstFrom(0, numCells); n != nil {
+		start = n.start
+	}
+	return start
+}
@@ -0,0 +1,73 @@
+package memsim
+
+import "sort"
+
+type BlockInfo struct {
+	Start     int
+	Size      int
+	Requested int
+	Order     int
+	Owner     string
+	Pad       int
+	Align     int
+}
+
+// Cells counts every cell the block occupies, including alignment padding.
+func (b BlockInfo) Cells() int {
+	return b.Pad + b.Size
+}
+
+func (b *block) info() BlockInfo {
+	return BlockInfo{b.start, b.size, b.requested, b.order, b.owner, b.pad, max(b.align, 1)}
+}
+
+// Blocks lists the live blocks in address order. Slab headers are allocator
+// bookkeeping and are left out.
+func (h *Heap) Blocks() []BlockInfo {
+	blocks := make([]BlockInfo, 0, len(h.blocks))
+	for _, b := range h.blocks {
+		if b.isSlab() {
+			continue
+		}
+		blocks = append(blocks, b.info())
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Start < blocks[j].Start })
+	return blocks
+}
+
+// Block looks up a live block by ID.
+func (h *Heap) Block(id int) (BlockInfo, bool) {
+	b, ok := h.blocks[id]
+	if !ok || b.isSlab() {
+		return BlockInfo{}, false
+	}
+	return b.info(), true
+}
+
+type OwnerUsage struct {
+	Owner  string
+	Blocks []int
+	Cells  int
+}
+
+// Owners groups the live blocks by owner tag, sorted by owner. Untagged
+// blocks are grouped under the empty owner.
+func (h *Heap) Owners() []OwnerUsage {
+	byOwner := make(map[string]*OwnerUsage)
+	for _, b := range h.Blocks() {
+		u, ok := byOwner[b.Owner]
+		if !ok {
+			u = &OwnerUsage{Owner: b.Owner}
+			byOwner[b.Owner] = u
+		}
+		u.Blocks = append(u.Blocks, b.Start)
+		u.Cells += b.Cells()
+	}
+
+	owners := make([]OwnerUsage, 0, len(byOwner))
+	for _, u := range byOwner {
+		owners = append(owners, *u)
+	}
+	sort.Slice(owners, func(i, j int) bool { return owners[i].Owner < owners[j].Owner })
+	return owners
+}
@@ -0,0 +1,180 @@
+package memsim
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+func (h *Heap) buddyInit(memSize int) int {
+	size, order := 1, 0
+	for size < memSize {
+		size <<= 1
+		order++
+	}
+	h.buddyFreeLists = make([][]int, order+1)
+	h.buddyFreeLists[order] = []int{0}
+	return size
+}
+
+// buddyFreeListsFor works out the free lists of a buddy heap from its memory
+// and blocks, splitting every range that is partly used into its halves.
+func buddyFreeListsFor(memory []int, blocks map[int]*block) ([][]int, error) {
+	order := blockOrder(len(memory))
+	if 1<<order != len(memory) {
+		return nil, errors.New("buddy memory must be a power of two cells")
+	}
+	lists := make([][]int, order+1)
+	found := 0
+	var walk func(start, k int) error
+	walk = func(start, k int) error {
+		cells := memory[start : start+1<<k]
+		if b, ok := blocks[start]; ok && b.order == k {
+			if b.size != 1<<k || b.pad != 0 || slices.ContainsFunc(cells, func(id int) bool { return id != start }) {
+				return fmt.Errorf("block %d is not a buddy block", start)
+			}
+			found++
+			return nil
+		}
+		if !slices.ContainsFunc(cells, func(id int) bool { return id != -1 }) {
+			lists[k] = append(lists[k], start)
+			return nil
+		}
+		if k == 0 {
+			return fmt.Errorf("block %d is not a buddy block", cells[0])
+		}
+		if err := walk(start, k-1); err != nil {
+			return err
+		}
+		return walk(start+1<<(k-1), k-1)
+	}
+	if err := walk(0, order); err != nil {
+		return nil, err
+	}
+	if found != len(blocks) {
+		return nil, errors.New("buddy blocks do not match memory")
+	}
+	return lists, nil
+}
+
+func blockOrder(numCells int) int {
+	order := 0
+	for 1<<order < numCells {
+		order++
+	}
+	return order
+}
+
+// Buddy blocks of 2^k cells always start at a multiple of 2^k, so an aligned
+// request only has to be rounded up to the alignment.
+func (h *Heap) buddyAllocate(numCells int, owner string, align int) (int, error) {
+	start := h.buddyTake(max(numCells, align), owner)
+	if start == -1 {
+		return -1, ErrOutOfMemory
+	}
+	b := h.blocks[start]
+	h.saveBlock(b)
+	b.requested = numCells
+	return start, nil
+}
+
+func (h *Heap) buddyTake(numCells int, owner string) int {
+	order := blockOrder(numCells)
+	j := order
+	for j < len(h.buddyFreeLists) && len(h.buddyFreeLists[j]) == 0 {
+		j++
+	}
+	if j >= len(h.buddyFreeLists) {
+		return -1
+	}
+
+	start := h.popLowest(j)
+	for j > order {
+		j--
+		h.pushFree(j, start+1<<j)
+	}
+
+	size := 1 << order
+	h.fillCells(start, size, start)
+	h.addBlock(&block{start: start, size: size, requested: numCells, order: order, owner: owner, align: 1})
+	return start
+}
+
+// buddyRealloc shrinks a block in place by handing its upper halves back to
+// the free lists, and moves it to a new block when it has to grow.
+func (h *Heap) buddyRealloc(b *block, newSize int) (int, error) {
+	order := blockOrder(newSize)
+	if order > b.order {
+		start := h.buddyTake(newSize, b.owner)
+		if start == -1 {
+			return -1, ErrOutOfMemory
+		}
+		h.freeBlock(b)
+		return start, nil
+	}
+
+	if b.order == order && b.requested == newSize {
+		return b.start, nil
+	}
+	h.saveBlock(b)
+	for b.order > order {
+		b.order--
+		half := b.start + 1<<b.order
+		h.clearCells(half, 1<<b.order)
+		h.pushFree(b.order, half)
+	}
+	b.size = 1 << b.order
+	b.requested = newSize
+	return b.start, nil
+}
+
+func (h *Heap) buddyFree(b *block) {
+	h.clearCells(b.first(), b.cells())
+	deleteKey(h, h.blocks, b.start)
+
+	start, order := b.start, b.order
+	for order < len(h.buddyFreeLists)-1 {
+		buddy := start ^ (1 << order)
+		i := indexOf(h.buddyFreeLists[order], buddy)
+		if i == -1 {
+			break
+		}
+		h.removeFree(order, i)
+		start = min(start, buddy)
+		order++
+	}
+	h.pushFree(order, start)
+}
+
+func (h *Heap) popLowest(order int) int {
+	list := h.buddyFreeLists[order]
+	lowest := 0
+	for i := range list {
+		if list[i] < list[lowest] {
+			lowest = i
+		}
+	}
+	start := list[lowest]
+	h.removeFree(order, lowest)
+	return start
+}
+
+// pushFree and removeFree never change a free list in place: an undo step
+// may still hold the list as it was.
+func (h *Heap) pushFree(order, start int) {
+	list := h.buddyFreeLists[order]
+	swap(h, &h.buddyFreeLists[order], append(list[:len(list):len(list)], start))
+}
+
+func (h *Heap) removeFree(order, i int) {
+	swap(h, &h.buddyFreeLists[order], slices.Delete(slices.Clone(h.buddyFreeLists[order]), i, i+1))
+}
+
+func indexOf(list []int, value int) int {
+	for i, v := range list {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
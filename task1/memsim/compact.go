@@ -0,0 +1,127 @@
+package memsim
+
+import "sort"
+
+type Relocation struct {
+	From, To int
+}
+
+// Compact slides every block towards cell 0 and returns the blocks that moved.
+// Old IDs keep resolving to the new ones through Resolve.
+//
+// An aligned block may need more padding at its new start. Padding counts
+// against quotas, so compaction can push an owner over its quota; it is not
+// refused for that, but reported as a QuotaOverrun event.
+func (h *Heap) Compact() ([]Relocation, error) {
+	if h.mode == "buddy" || h.mode == "slab" || h.mode == "paging" {
+		return nil, unsupported(h.mode)
+	}
+	return h.compactMemory(), nil
+}
+
+func (h *Heap) compactMemory() []Relocation {
+	starts := make([]int, 0, len(h.blocks))
+	for start := range h.blocks {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+
+	var moved []Relocation
+	padded := make(map[string]bool)
+	compacted := make(map[int]*block, len(h.blocks))
+	memory := make([]int, len(h.memory))
+	next := 0
+	for _, start := range starts {
+		b := h.blocks[start]
+		aligned := alignUp(next, max(b.align, 1))
+		if b.start != aligned || b.pad != aligned-next {
+			h.saveBlock(b)
+		}
+		if b.start != aligned {
+			moved = append(moved, Relocation{b.start, aligned})
+			b.start = aligned
+		}
+		if aligned-next > b.pad {
+			padded[b.owner] = true
+		}
+		b.pad = aligned - next
+		compacted[b.start] = b
+		deleteKey(h, h.freed, b.start)
+		for i := next; i < aligned+b.size; i++ {
+			memory[i] = b.start
+		}
+		next = aligned + b.size
+	}
+	for i := next; i < len(memory); i++ {
+		memory[i] = -1
+	}
+
+	h.writeCells(0, memory)
+	if len(moved) > 0 {
+		swap(h, &h.blocks, compacted)
+	}
+
+	// Old IDs of a moved block follow it. They are all collected first, as
+	// a block may move to where another one was.
+	newIDs := make(map[int]int, len(moved))
+	var followers []Relocation
+	for _, r := range moved {
+		newIDs[r.From] = r.To
+		for from := range h.aliases[r.From] {
+			followers = append(followers, Relocation{from, r.To})
+		}
+	}
+	for _, r := range append(followers, moved...) {
+		h.relocate(r.From, r.To)
+	}
+	h.retarget(newIDs)
+	h.reportOverruns(padded)
+
+	return moved
+}
+
+// reportOverruns records a QuotaOverrun event for every one of owners that
+// is now over its quota.
+func (h *Heap) reportOverruns(owners map[string]bool) {
+	var over []Quota
+	for owner := range owners {
+		if limit, ok := h.quotas[owner]; ok {
+			if used := h.usage(owner); used > limit {
+				over = append(over, Quota{owner, limit, used})
+			}
+		}
+	}
+	sort.Slice(over, func(i, j int) bool { return over[i].Owner < over[j].Owner })
+	for _, q := range over {
+		h.events = append(h.events, Event{Kind: QuotaOverrun, Quota: q})
+	}
+}
+
+// Resolve follows compactions from an old block ID to the block's current ID.
+func (h *Heap) Resolve(blockID int) (int, bool) {
+	if to, ok := h.relocated[blockID]; ok {
+		return to, true
+	}
+	_, ok := h.blocks[blockID]
+	return blockID, ok
+}
+
+func (h *Heap) relocate(from, to int) {
+	h.unrelocate(from)
+	setKey(h, h.relocated, from, to)
+	link(h, h.aliases, to, from)
+}
+
+func (h *Heap) unrelocate(from int) {
+	if to, ok := h.relocated[from]; ok {
+		unlink(h, h.aliases, to, from)
+		deleteKey(h, h.relocated, from)
+	}
+}
+
+// forgetRelocations drops the old IDs that resolve to a block being freed.
+func (h *Heap) forgetRelocations(blockID int) {
+	for from := range h.aliases[blockID] {
+		h.unrelocate(from)
+	}
+}
@@ -0,0 +1,238 @@
+package memsim
+
+import "math/rand"
+
+// freeIndex keeps the holes of memory in two treaps: one ordered by start
+// cell for first/next/worst fit and coalescing, and one ordered by size for
+// best fit. Every node also tracks the largest hole in its subtree, which
+// lets searches skip whole subtrees that cannot satisfy a request.
+type freeIndex struct {
+	byStart holeTree
+	bySize  holeTree
+	free    int
+}
+
+func newFreeIndex() *freeIndex {
+	return &freeIndex{
+		byStart: holeTree{less: func(a, b hole) bool { return a.start < b.start }},
+		bySize: holeTree{less: func(a, b hole) bool {
+			return a.size < b.size || a.size == b.size && a.start < b.start
+		}},
+	}
+}
+
+func (idx *freeIndex) add(h hole) {
+	idx.byStart.insert(h)
+	idx.bySize.insert(h)
+	idx.free += h.size
+}
+
+func (idx *freeIndex) remove(h hole) {
+	idx.byStart.delete(h)
+	idx.bySize.delete(h)
+	idx.free -= h.size
+}
+
+// take marks cells [start, start+size) as used. They must lie inside one hole.
+func (idx *freeIndex) take(start, size int) {
+	n := idx.byStart.floor(start)
+	h := n.hole
+	idx.remove(h)
+	if start > h.start {
+		idx.add(hole{h.start, start - h.start})
+	}
+	if end := h.start + h.size; start+size < end {
+		idx.add(hole{start + size, end - start - size})
+	}
+}
+
+// release marks cells [start, start+size) as free, merging the new hole with
+// its neighbours.
+func (idx *freeIndex) release(start, size int) {
+	h := hole{start, size}
+	if left := idx.byStart.floor(start - 1); left != nil && left.start+left.size == start {
+		idx.remove(left.hole)
+		h = hole{left.start, left.size + h.size}
+	}
+	if right := idx.byStart.floor(start + size); right != nil && right.start == start+size {
+		idx.remove(right.hole)
+		h.size += right.size
+	}
+	idx.add(h)
+}
+
+func (idx *freeIndex) rebuild(memory []int) {
+	*idx = *newFreeIndex()
+	for i := 0; i < len(memory); {
+		if memory[i] != -1 {
+			i++
+			continue
+		}
+		start := i
+		for i < len(memory) && memory[i] == -1 {
+			i++
+		}
+		idx.add(hole{start, i - start})
+	}
+}
+
+func (idx *freeIndex) holes() []hole {
+	var holes []hole
+	var walk func(n *holeNode)
+	walk = func(n *holeNode) {
+		if n == nil {
+			return
+		}
+		walk(n.left)
+		holes = append(holes, n.hole)
+		walk(n.right)
+	}
+	walk(idx.byStart.root)
+	return holes
+}
+
+func (idx *freeIndex) largest() int {
+	if idx.byStart.root == nil {
+		return 0
+	}
+	return idx.byStart.root.maxSize
+}
+
+// firstFrom returns the lowest-addressed hole starting at or after from that
+// has at least numCells cells.
+func (idx *freeIndex) firstFrom(from, numCells int) *holeNode {
+	var search func(n *holeNode) *holeNode
+	search = func(n *holeNode) *holeNode {
+		if n == nil || n.maxSize < numCells {
+			return nil
+		}
+		if n.start < from {
+			return search(n.right)
+		}
+		if found := search(n.left); found != nil {
+			return found
+		}
+		if n.size >= numCells {
+			return n
+		}
+		return search(n.right)
+	}
+	return search(idx.byStart.root)
+}
+
+// firstAligned returns the lowest-addressed hole that can hold numCells cells
+// starting at a multiple of align.
+func (idx *freeIndex) firstAligned(numCells, align int) *holeNode {
+	var search func(n *holeNode) *holeNode
+	search = func(n *holeNode) *holeNode {
+		if n == nil || n.maxSize < numCells {
+			return nil
+		}
+		if found := search(n.left); found != nil {
+			return found
+		}
+		if alignUp(n.start, align)+numCells <= n.start+n.size {
+			return n
+		}
+		return search(n.right)
+	}
+	return search(idx.byStart.root)
+}
+
+// smallestFit returns the smallest hole with at least numCells cells, the
+// lowest-addressed one among equals.
+func (idx *freeIndex) smallestFit(numCells int) *holeNode {
+	var found *holeNode
+	for n := idx.bySize.root; n != nil; {
+		if n.size >= numCells {
+			found = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return found
+}
+
+type holeNode struct {
+	hole
+	priority    int
+	maxSize     int
+	left, right *holeNode
+}
+
+func (n *holeNode) update() {
+	n.maxSize = n.size
+	if n.left != nil {
+		n.maxSize = max(n.maxSize, n.left.maxSize)
+	}
+	if n.right != nil {
+		n.maxSize = max(n.maxSize, n.right.maxSize)
+	}
+}
+
+type holeTree struct {
+	root *holeNode
+	less func(a, b hole) bool
+}
+
+func (t *holeTree) insert(h hole) {
+	left, right := split(t.root, func(k hole) bool { return t.less(k, h) })
+	n := &holeNode{hole: h, priority: rand.Int(), maxSize: h.size}
+	t.root = merge(merge(left, n), right)
+}
+
+func (t *holeTree) delete(h hole) {
+	left, rest := split(t.root, func(k hole) bool { return t.less(k, h) })
+	_, right := split(rest, func(k hole) bool { return !t.less(h, k) })
+	t.root = merge(left, right)
+}
+
+// floor returns the node with the greatest start not above start. It is only
+// meaningful on a tree ordered by start.
+func (t *holeTree) floor(start int) *holeNode {
+	var found *holeNode
+	for n := t.root; n != nil; {
+		if n.start <= start {
+			found = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return found
+}
+
+// split divides n into the nodes whose keys satisfy goesLeft and the rest.
+func split(n *holeNode, goesLeft func(hole) bool) (*holeNode, *holeNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if goesLeft(n.hole) {
+		l, r := split(n.right, goesLeft)
+		n.right = l
+		n.update()
+		return n, r
+	}
+	l, r := split(n.left, goesLeft)
+	n.left = r
+	n.update()
+	return l, n
+}
+
+func merge(a, b *holeNode) *holeNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.priority > b.priority {
+		a.right = merge(a.right, b)
+		a.update()
+		return a
+	}
+	b.left = merge(a, b.left)
+	b.update()
+	return b
+}
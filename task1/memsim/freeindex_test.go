@@ -0,0 +1,283 @@
+package memsim
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// scanHoles finds the holes of memory cell by cell, as the allocator did
+// before it had a free index.
+func scanHoles(memory []int) []hole {
+	var holes []hole
+	for i := 0; i < len(memory); {
+		if memory[i] != -1 {
+			i++
+			continue
+		}
+		start := i
+		for i < len(memory) && memory[i] == -1 {
+			i++
+		}
+		holes = append(holes, hole{start, i - start})
+	}
+	return holes
+}
+
+// checkFreeIndex fails unless both treaps of h hold exactly the holes of its
+// memory, in order, and every node knows the largest hole below it.
+func checkFreeIndex(t *testing.T, h *Heap) {
+	t.Helper()
+	want := scanHoles(h.memory)
+	if got := inOrder(t, h.freeIdx.byStart.root); !slices.Equal(got, want) {
+		t.Fatalf("holes by start = %v, memory has %v", got, want)
+	}
+	bySize := inOrder(t, h.freeIdx.bySize.root)
+	sorted := slices.Clone(want)
+	slices.SortFunc(sorted, func(a, b hole) int {
+		if a.size != b.size {
+			return a.size - b.size
+		}
+		return a.start - b.start
+	})
+	if !slices.Equal(bySize, sorted) {
+		t.Fatalf("holes by size = %v, want %v", bySize, sorted)
+	}
+	free := 0
+	for _, hl := range want {
+		free += hl.size
+	}
+	if h.freeIdx.free != free {
+		t.Fatalf("free = %d, memory has %d free cells", h.freeIdx.free, free)
+	}
+}
+
+// inOrder lists the holes of a treap in order and checks maxSize on the way.
+func inOrder(t *testing.T, root *holeNode) []hole {
+	var holes []hole
+	var walk func(n *holeNode) int
+	walk = func(n *holeNode) int {
+		if n == nil {
+			return 0
+		}
+		largest := walk(n.left)
+		holes = append(holes, n.hole)
+		largest = max(largest, n.size, walk(n.right))
+		if n.maxSize != largest {
+			t.Fatalf("hole %v has maxSize %d, want %d", n.hole, n.maxSize, largest)
+		}
+		return largest
+	}
+	walk(root)
+	return holes
+}
+
+// randomOp runs one random allocate, free, realloc, compact, undo or redo on
+// h and records it for undo like the REPL does.
+func randomOp(rng *rand.Rand, h *Heap) {
+	h.Begin()
+	defer h.Commit()
+	blocks := h.Blocks()
+	switch op := rng.Intn(20); {
+	case op < 8 || len(blocks) == 0:
+		h.Allocate(1+rng.Intn(24), "", 1<<rng.Intn(3))
+	case op < 13:
+		h.Free(blocks[rng.Intn(len(blocks))].Start)
+	case op < 16:
+		h.Realloc(blocks[rng.Intn(len(blocks))].Start, 1+rng.Intn(32))
+	case op < 17:
+		h.Compact()
+	case op < 19:
+		h.Commit()
+		h.Undo()
+	default:
+		h.Commit()
+		h.Redo()
+	}
+}
+
+func TestFreeIndexMatchesMemory(t *testing.T) {
+	for _, mode := range []string{"contiguous", "buddy", "slab", "managed"} {
+		for _, strategy := range Strategies {
+			t.Run(mode+"/"+strategy, func(t *testing.T) {
+				h, err := NewHeap(Config{Mode: mode, Strategy: strategy, Size: 512, Width: 16})
+				if err != nil {
+					t.Fatal(err)
+				}
+				rng := rand.New(rand.NewSource(7))
+				for i := 0; i < 3000; i++ {
+					randomOp(rng, h)
+					checkFreeIndex(t, h)
+				}
+			})
+		}
+	}
+}
+
+const benchCells = 1_000_000
+
+func newBenchHeap(b *testing.B, strategy string) *Heap {
+	b.Helper()
+	h, err := NewHeap(Config{Mode: "contiguous", Strategy: strategy, Size: benchCells, Width: 100})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return h
+}
+
+// fragment fills h with blocks of 1 to 16 cells and frees every other one,
+// leaving tens of thousands of holes.
+func fragment(b *testing.B, h *Heap) {
+	b.Helper()
+	rng := rand.New(rand.NewSource(1))
+	var ids []int
+	for {
+		id, err := h.Allocate(1+rng.Intn(16), "", 1)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	for i := 0; i < len(ids); i += 2 {
+		if err := h.Free(ids[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAllocateFree allocates a block on a fragmented heap and frees it
+// again, so every iteration searches the same holes.
+func BenchmarkAllocateFree(b *testing.B) {
+	for _, strategy := range Strategies {
+		b.Run(strategy, func(b *testing.B) {
+			h := newBenchHeap(b, strategy)
+			fragment(b, h)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id, err := h.Allocate(1+i%16, "", 1)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := h.Free(id); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAllocateFreeAligned(b *testing.B) {
+	h := newBenchHeap(b, "first")
+	fragment(b, h)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id, err := h.Allocate(4, "", 8)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := h.Free(id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAllocateFreeAfterCompact compacts a fragmented heap first, which
+// leaves an old ID behind for every block that moved.
+func BenchmarkAllocateFreeAfterCompact(b *testing.B) {
+	h := newBenchHeap(b, "first")
+	fragment(b, h)
+	h.Compact()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id, err := h.Allocate(1+i%16, "", 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := h.Free(id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAllocateFreeManyRefs frees blocks of a managed heap in which
+// every live block references the next one.
+func BenchmarkAllocateFreeManyRefs(b *testing.B) {
+	h, err := NewHeap(Config{Mode: "managed", Strategy: "first", Size: benchCells, Width: 100})
+	if err != nil {
+		b.Fatal(err)
+	}
+	fragment(b, h)
+	blocks := h.Blocks()
+	for i := 0; i+1 < len(blocks); i++ {
+		if err := h.AddRef(blocks[i].Start, blocks[i+1].Start); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id, err := h.Allocate(1+i%16, "", 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := h.AddRef(blocks[i%len(blocks)].Start, id); err != nil {
+			b.Fatal(err)
+		}
+		if err := h.Free(id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCellScanAllocateFree is the baseline for BenchmarkAllocateFree:
+// first fit over the same fragmented memory, finding holes by scanning the
+// cells as the allocator did before the free index.
+func BenchmarkCellScanAllocateFree(b *testing.B) {
+	h := newBenchHeap(b, "first")
+	fragment(b, h)
+	memory := slices.Clone(h.memory)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		numCells, start := 1+i%16, -1
+		for _, hl := range scanHoles(memory) {
+			if hl.size >= numCells {
+				start = hl.start
+				break
+			}
+		}
+		if start == -1 {
+			b.Fatal("no hole")
+		}
+		for c := start; c < start+numCells; c++ {
+			memory[c] = start
+		}
+		for c := start; c < start+numCells; c++ {
+			memory[c] = -1
+		}
+	}
+}
+
+// BenchmarkFillAndFree fills an empty heap with 1000-cell blocks and frees
+// them all, merging the holes back into one.
+func BenchmarkFillAndFree(b *testing.B) {
+	for _, strategy := range Strategies {
+		b.Run(strategy, func(b *testing.B) {
+			h := newBenchHeap(b, strategy)
+			ids := make([]int, 0, benchCells/1000)
+			for i := 0; i < b.N; i++ {
+				ids = ids[:0]
+				for len(ids) < benchCells/1000 {
+					id, err := h.Allocate(1000, "", 1)
+					if err != nil {
+						b.Fatal(err)
+					}
+					ids = append(ids, id)
+				}
+				for _, id := range ids {
+					if err := h.Free(id); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
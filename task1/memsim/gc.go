@@ -0,0 +1,191 @@
+package memsim
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrNoReference = errors.New("no such reference")
+	ErrNotRoot     = errors.New("not a root")
+)
+
+// AddRef records that block from holds a reference to block to. References
+// and roots only exist in managed mode.
+func (h *Heap) AddRef(from, to int) error {
+	if h.mode != "managed" {
+		return unsupported(h.mode)
+	}
+	for _, id := range []int{from, to} {
+		if b, ok := h.blocks[id]; !ok || b.isSlab() {
+			return &BlockError{id, ErrInvalidBlock}
+		}
+	}
+	if !h.refs[from][to] {
+		h.linkRef(from, to)
+	}
+	return nil
+}
+
+func (h *Heap) RemoveRef(from, to int) error {
+	if h.mode != "managed" {
+		return unsupported(h.mode)
+	}
+	if !h.refs[from][to] {
+		return &BlockError{from, ErrNoReference}
+	}
+	h.unlinkRef(from, to)
+	return nil
+}
+
+func (h *Heap) AddRoot(id int) error {
+	if h.mode != "managed" {
+		return unsupported(h.mode)
+	}
+	if b, ok := h.blocks[id]; !ok || b.isSlab() {
+		return &BlockError{id, ErrInvalidBlock}
+	}
+	if !h.roots[id] {
+		setKey(h, h.roots, id, true)
+	}
+	return nil
+}
+
+func (h *Heap) RemoveRoot(id int) error {
+	if h.mode != "managed" {
+		return unsupported(h.mode)
+	}
+	if !h.roots[id] {
+		return &BlockError{id, ErrNotRoot}
+	}
+	deleteKey(h, h.roots, id)
+	return nil
+}
+
+func (h *Heap) linkRef(from, to int) {
+	link(h, h.refs, from, to)
+	link(h, h.referrers, to, from)
+}
+
+func (h *Heap) unlinkRef(from, to int) {
+	unlink(h, h.refs, from, to)
+	unlink(h, h.referrers, to, from)
+}
+
+// dropRefs forgets every reference from or to a block that is being freed.
+func (h *Heap) dropRefs(id int) {
+	for to := range h.refs[id] {
+		h.unlinkRef(id, to)
+	}
+	for from := range h.referrers[id] {
+		h.unlinkRef(from, id)
+	}
+	deleteKey(h, h.roots, id)
+}
+
+// retarget rewrites the references and roots of blocks that were moved. All
+// of them are taken out before any is put back, as a block may move to where
+// another one was.
+func (h *Heap) retarget(newIDs map[int]int) {
+	rename := func(id int) int {
+		if to, ok := newIDs[id]; ok {
+			return to
+		}
+		return id
+	}
+
+	refs := make(map[[2]int]bool)
+	var roots []int
+	for id := range newIDs {
+		for to := range h.refs[id] {
+			refs[[2]int{id, to}] = true
+		}
+		for from := range h.referrers[id] {
+			refs[[2]int{from, id}] = true
+		}
+		if h.roots[id] {
+			roots = append(roots, id)
+		}
+	}
+	for ref := range refs {
+		h.unlinkRef(ref[0], ref[1])
+	}
+	for _, id := range roots {
+		deleteKey(h, h.roots, id)
+	}
+	for ref := range refs {
+		h.linkRef(rename(ref[0]), rename(ref[1]))
+	}
+	for _, id := range roots {
+		setKey(h, h.roots, rename(id), true)
+	}
+}
+
+type Collection struct {
+	Freed []int
+	Cells int
+	Moved []Relocation
+}
+
+// CollectGarbage marks every block reachable from the roots and frees the
+// rest. With compact set, the surviving blocks are then slid towards cell 0.
+func (h *Heap) CollectGarbage(compact bool) (Collection, error) {
+	if h.mode != "managed" {
+		return Collection{}, unsupported(h.mode)
+	}
+	marked := make(map[int]bool)
+	var stack []int
+	for id := range h.roots {
+		stack = append(stack, id)
+	}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if marked[id] {
+			continue
+		}
+		marked[id] = true
+		for to := range h.refs[id] {
+			stack = append(stack, to)
+		}
+	}
+
+	var c Collection
+	for id, b := range h.blocks {
+		if !marked[id] && !b.isSlab() {
+			c.Freed = append(c.Freed, id)
+			c.Cells += b.cells()
+		}
+	}
+	sort.Ints(c.Freed)
+	for _, id := range c.Freed {
+		h.freeBlock(h.blocks[id])
+	}
+
+	if compact {
+		c.Moved = h.compactMemory()
+	}
+	h.checkPressure()
+	return c, nil
+}
+
+func (h *Heap) Roots() []int {
+	roots := make([]int, 0, len(h.roots))
+	for id := range h.roots {
+		roots = append(roots, id)
+	}
+	sort.Ints(roots)
+	return roots
+}
+
+// Refs returns the sorted targets of every block that references others.
+func (h *Heap) Refs() map[int][]int {
+	refs := make(map[int][]int, len(h.refs))
+	for from, targets := range h.refs {
+		for to := range targets {
+			refs[from] = append(refs[from], to)
+		}
+		sort.Ints(refs[from])
+	}
+	return refs
+}
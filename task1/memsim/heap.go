@@ -0,0 +1,439 @@
+// Package memsim simulates a memory of cells handed out by placement
+// strategies, buddy and slab allocators, a paging MMU or a garbage collector.
+// Operations return their results and errors instead of printing them, so the
+// simulator can be driven from the task1 REPL, from other tools and from tests.
+package memsim
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var Modes = []string{"contiguous", "buddy", "paging", "slab", "managed"}
+
+var (
+	ErrOutOfMemory  = errors.New("not enough memory")
+	ErrInvalidBlock = errors.New("not a live block")
+	ErrDoubleFree   = fmt.Errorf("%w: already freed", ErrInvalidBlock)
+	ErrInvalidSize  = errors.New("invalid number of cells")
+	ErrAlignment    = errors.New("invalid alignment")
+	ErrUnsupported  = errors.New("not supported in this mode")
+)
+
+// BlockError reports an operation on block ID that failed with Err.
+type BlockError struct {
+	ID  int
+	Err error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("block %d: %v", e.ID, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
+
+func unsupported(mode string) error {
+	return fmt.Errorf("%w (%s)", ErrUnsupported, mode)
+}
+
+// Heap is one simulated memory together with everything the allocator keeps
+// about it.
+type Heap struct {
+	mode      string
+	memory    []int
+	width     int
+	allocator allocator
+	blocks    map[int]*block
+	freeIdx   *freeIndex
+
+	// buddyFreeLists[k] holds the start cells of free blocks of 2^k cells.
+	buddyFreeLists [][]int
+	slabsByClass   map[int][]*slab
+
+	// relocated maps block IDs that were moved by compaction to their current
+	// IDs, so an ID handed out before any number of compactions can be resolved.
+	relocated map[int]int
+	// aliases is the inverse of relocated: the old IDs of every current ID.
+	aliases map[int]map[int]bool
+	// freed remembers the IDs of blocks that were freed and not reused since, so
+	// a second free of the same ID can be told apart from a bogus one.
+	freed map[int]bool
+
+	// refs and roots form the object graph of a managed heap. referrers is
+	// the inverse of refs, so freeing a block only visits its own references.
+	refs      map[int]map[int]bool
+	referrers map[int]map[int]bool
+	roots     map[int]bool
+
+	// journal collects the undo steps of the operation being recorded.
+	recording            bool
+	journal              change
+	undoStack, redoStack []change
+
+	quotas                      map[string]int
+	lowWatermark, highWatermark int
+	pressure                    Pressure
+	oomKiller                   bool
+	events                      []Event
+
+	pageSize   int
+	processes  map[int]*process
+	frames     []*frame
+	policy     string
+	clockHand  int
+	clock      int
+	references []pageRef
+	pageFaults int
+}
+
+// Config describes a new heap. Width is the number of cells per row when the
+// heap is rendered, and PageSize only matters in paging mode.
+type Config struct {
+	Mode     string
+	Strategy string
+	Size     int
+	Width    int
+	PageSize int
+}
+
+func NewHeap(cfg Config) (*Heap, error) {
+	if !slices.Contains(Modes, cfg.Mode) {
+		return nil, fmt.Errorf("unknown mode %q (available: %s)", cfg.Mode, strings.Join(Modes, ", "))
+	}
+	if cfg.Size <= 0 || cfg.Width <= 0 {
+		return nil, errors.New("invalid memory size or max output width")
+	}
+	if cfg.Mode == "paging" && cfg.PageSize <= 0 {
+		return nil, errors.New("invalid page size")
+	}
+	a, err := newAllocator(cfg.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Heap{
+		mode:         cfg.Mode,
+		width:        cfg.Width,
+		allocator:    a,
+		blocks:       make(map[int]*block),
+		freeIdx:      newFreeIndex(),
+		slabsByClass: make(map[int][]*slab),
+		relocated:    make(map[int]int),
+		aliases:      make(map[int]map[int]bool),
+		freed:        make(map[int]bool),
+		refs:         make(map[int]map[int]bool),
+		referrers:    make(map[int]map[int]bool),
+		roots:        make(map[int]bool),
+		quotas:       make(map[string]int),
+		pageSize:     cfg.PageSize,
+		processes:    make(map[int]*process),
+		policy:       "fifo",
+	}
+
+	size := cfg.Size
+	if h.mode == "buddy" {
+		size = h.buddyInit(size)
+	}
+	h.memory = make([]int, size)
+	for i := range h.memory {
+		h.memory[i] = -1
+	}
+	h.freeIdx.add(hole{0, size})
+
+	if h.mode == "paging" {
+		h.pagingInit(size)
+		if len(h.frames) == 0 {
+			return nil, errors.New("memory is smaller than one page")
+		}
+	}
+	return h, nil
+}
+
+type block struct {
+	start     int
+	size      int
+	requested int
+	order     int
+	owner     string
+	pad       int
+	align     int
+	slab      *slab
+}
+
+// first and cells describe every cell a block occupies, including the
+// padding in front of it that alignment wasted.
+func (b *block) first() int {
+	return b.start - b.pad
+}
+
+func (b *block) cells() int {
+	return b.pad + b.size
+}
+
+func alignUp(n, align int) int {
+	return (n + align - 1) / align * align
+}
+
+func (h *Heap) Mode() string {
+	return h.mode
+}
+
+// Size is the number of cells, which buddy mode rounds up to a power of two.
+func (h *Heap) Size() int {
+	return len(h.memory)
+}
+
+func (h *Heap) Width() int {
+	return h.width
+}
+
+func (h *Heap) Strategy() string {
+	return h.allocator.Name()
+}
+
+func (h *Heap) SetStrategy(name string) error {
+	if h.mode == "buddy" || h.mode == "paging" {
+		return unsupported(h.mode)
+	}
+	a, err := newAllocator(name)
+	if err != nil {
+		return err
+	}
+	if !sameAllocator(a, h.allocator) {
+		swap(h, &h.allocator, a)
+	}
+	return nil
+}
+
+func (h *Heap) FreeCells() int {
+	return h.freeIdx.free
+}
+
+func (h *Heap) LargestHole() int {
+	return h.freeIdx.largest()
+}
+
+// BlockAt returns the ID of the block that cell belongs to.
+func (h *Heap) BlockAt(cell int) (int, bool) {
+	if cell < 0 || cell >= len(h.memory) || h.memory[cell] == -1 {
+		return -1, false
+	}
+	return h.memory[cell], true
+}
+
+// Allocate reserves numCells cells starting at a multiple of align and
+// returns the block ID, which is the block's first cell.
+func (h *Heap) Allocate(numCells int, owner string, align int) (int, error) {
+	if numCells <= 0 {
+		return -1, ErrInvalidSize
+	}
+	if align <= 0 || h.mode == "buddy" && align&(align-1) != 0 {
+		return -1, ErrAlignment
+	}
+	if h.mode == "paging" {
+		return -1, unsupported(h.mode)
+	}
+	if err := h.checkQuota(owner, h.cost(numCells, align)); err != nil {
+		return -1, err
+	}
+	defer h.checkPressure()
+
+	for {
+		start, err := h.allocate(numCells, owner, align)
+		if err != ErrOutOfMemory || !h.killLargestOwner(owner, numCells) {
+			return start, err
+		}
+	}
+}
+
+func (h *Heap) allocate(numCells int, owner string, align int) (int, error) {
+	if h.mode == "buddy" {
+		return h.buddyAllocate(numCells, owner, align)
+	}
+	return h.allocateBlock(numCells, owner, align, 0)
+}
+
+// cost is the number of cells an allocation of numCells takes once buddy
+// and slab allocators have rounded it up, not counting alignment padding.
+func (h *Heap) cost(numCells, align int) int {
+	switch {
+	case h.mode == "buddy":
+		return 1 << blockOrder(max(numCells, align))
+	case h.mode == "slab" && align == 1 && sizeClass(numCells) != -1:
+		return sizeClass(numCells)
+	}
+	return numCells
+}
+
+// allocateBlock serves numCells from a slab or places a new block. Placing
+// it may waste padding cells, which count against the quota of owner only
+// once the hole is known; credit is the number of cells the caller frees
+// right after, when a block is being moved.
+func (h *Heap) allocateBlock(numCells int, owner string, align, credit int) (int, error) {
+	if h.mode == "slab" && align == 1 && sizeClass(numCells) != -1 {
+		if start := h.slabTake(numCells, owner); start != -1 {
+			return start, nil
+		}
+		return -1, ErrOutOfMemory
+	}
+
+	first, start := h.locate(numCells, align)
+	if first == -1 {
+		return -1, ErrOutOfMemory
+	}
+	if err := h.checkQuota(owner, start-first+numCells-credit); err != nil {
+		return -1, err
+	}
+	h.placeBlock(first, start, numCells)
+	h.addBlock(&block{start: start, size: numCells, requested: numCells, owner: owner, pad: start - first, align: align})
+	return start, nil
+}
+
+// Free releases a block. Freeing an ID that is not a live block fails with
+// ErrDoubleFree if the block was freed before and with ErrInvalidBlock
+// otherwise.
+func (h *Heap) Free(blockID int) error {
+	if h.mode == "paging" {
+		return unsupported(h.mode)
+	}
+	b, ok := h.blocks[blockID]
+	if !ok || b.isSlab() {
+		if h.freed[blockID] {
+			return &BlockError{blockID, ErrDoubleFree}
+		}
+		return &BlockError{blockID, ErrInvalidBlock}
+	}
+	h.freeBlock(b)
+	h.checkPressure()
+	return nil
+}
+
+func (h *Heap) freeBlock(b *block) {
+	blockID := b.start
+	if h.mode == "buddy" {
+		h.buddyFree(b)
+	} else if b.slab != nil {
+		h.slabFree(b)
+	} else {
+		h.clearCells(b.first(), b.cells())
+		deleteKey(h, h.blocks, blockID)
+	}
+	setKey(h, h.freed, blockID, true)
+	h.forgetRelocations(blockID)
+	h.dropRefs(blockID)
+}
+
+// locate finds room for numCells cells starting at a multiple of align
+// without taking it, and returns its first cell and the aligned start, or -1
+// twice. It asks the strategy for align-1 extra cells so that any hole it
+// picks can fit the block; the cells skipped in front of the aligned start
+// become padding. When no hole is that large, a smaller one may still have
+// an aligned cell early enough, so the lowest-addressed such hole is used
+// instead.
+func (h *Heap) locate(numCells, align int) (first, start int) {
+	first = h.allocator.Find(h.freeIdx, numCells+align-1)
+	if first == -1 && align > 1 {
+		if n := h.freeIdx.firstAligned(numCells, align); n != nil {
+			first = n.start
+		}
+	}
+	if first == -1 {
+		return -1, -1
+	}
+	return first, alignUp(first, align)
+}
+
+// placeBlock marks the room that locate found as used by a block of
+// numCells cells at start, and moves the next fit cursor past it.
+func (h *Heap) placeBlock(first, start, numCells int) {
+	h.fillCells(first, start-first+numCells, start)
+	if a, ok := h.allocator.(*nextFit); ok && a.last != start+numCells {
+		swap(h, &a.last, start+numCells)
+	}
+}
+
+func (h *Heap) addBlock(b *block) {
+	setKey(h, h.blocks, b.start, b)
+	h.unrelocate(b.start)
+	deleteKey(h, h.freed, b.start)
+}
+
+// Realloc resizes a block in place when it can and otherwise moves it,
+// returning the block's possibly new ID.
+func (h *Heap) Realloc(blockID, newSize int) (int, error) {
+	if h.mode == "paging" {
+		return -1, unsupported(h.mode)
+	}
+	b, ok := h.blocks[blockID]
+	if !ok || b.isSlab() {
+		return -1, &BlockError{blockID, ErrInvalidBlock}
+	}
+	if newSize <= 0 {
+		return -1, ErrInvalidSize
+	}
+	if err := h.checkQuota(b.owner, h.cost(newSize, b.align)-b.size); err != nil {
+		return -1, err
+	}
+	defer h.checkPressure()
+
+	if h.mode == "buddy" {
+		return h.buddyRealloc(b, newSize)
+	}
+	if b.slab != nil {
+		return h.slabRealloc(b, newSize)
+	}
+
+	end := b.start + b.size
+	if newSize == b.size {
+		return b.start, nil
+	}
+	if newSize < b.size {
+		h.clearCells(b.start+newSize, end-b.start-newSize)
+		h.saveBlock(b)
+		b.size, b.requested = newSize, newSize
+		return b.start, nil
+	}
+
+	if next := h.freeIdx.byStart.floor(end); next != nil && next.start == end && next.size >= newSize-b.size {
+		h.fillCells(end, newSize-b.size, b.start)
+		h.saveBlock(b)
+		b.size, b.requested = newSize, newSize
+		return b.start, nil
+	}
+
+	first, start := h.locate(newSize, b.align)
+	if first == -1 {
+		return -1, ErrOutOfMemory
+	}
+	if err := h.checkQuota(b.owner, start-first+newSize-b.cells()); err != nil {
+		return -1, err
+	}
+	h.placeBlock(first, start, newSize)
+	h.retarget(map[int]int{blockID: start})
+	h.freeBlock(b)
+	h.addBlock(&block{start: start, size: newSize, requested: newSize, owner: b.owner, pad: start - first, align: b.align})
+	return start, nil
+}
+
+func (h *Heap) fillCells(start, size, id int) {
+	h.saveCells(start, size)
+	for i := start; i < start+size; i++ {
+		h.memory[i] = id
+	}
+	h.freeIdx.take(start, size)
+}
+
+func (h *Heap) clearCells(start, size int) {
+	if size == 0 {
+		return
+	}
+	h.saveCells(start, size)
+	for i := start; i < start+size; i++ {
+		h.memory[i] = -1
+	}
+	h.freeIdx.release(start, size)
+}
@@ -0,0 +1,223 @@
+package memsim
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func newTestHeap(t *testing.T, mode string, size int) *Heap {
+	t.Helper()
+	h, err := NewHeap(Config{Mode: mode, Strategy: "first", Size: size, Width: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func mustAllocate(t *testing.T, h *Heap, numCells int, owner string, align int) int {
+	t.Helper()
+	id, err := h.Allocate(numCells, owner, align)
+	if err != nil {
+		t.Fatalf("Allocate(%d, %q, %d): %v", numCells, owner, align, err)
+	}
+	return id
+}
+
+func TestAllocateOutOfMemory(t *testing.T) {
+	for _, mode := range []string{"contiguous", "buddy", "slab", "managed"} {
+		t.Run(mode, func(t *testing.T) {
+			h := newTestHeap(t, mode, 64)
+			if _, err := h.Allocate(65, "", 1); !errors.Is(err, ErrOutOfMemory) {
+				t.Errorf("Allocate(65) on 64 cells: got %v, want ErrOutOfMemory", err)
+			}
+			for {
+				if _, err := h.Allocate(16, "", 1); err != nil {
+					if !errors.Is(err, ErrOutOfMemory) {
+						t.Fatalf("Allocate(16) on a full heap: got %v, want ErrOutOfMemory", err)
+					}
+					break
+				}
+			}
+			free := h.FreeCells()
+			if _, err := h.Allocate(free+1, "", 1); !errors.Is(err, ErrOutOfMemory) {
+				t.Errorf("Allocate(%d) with %d free cells: got %v, want ErrOutOfMemory", free+1, free, err)
+			}
+			if h.FreeCells() != free {
+				t.Errorf("failed Allocate changed free cells from %d to %d", free, h.FreeCells())
+			}
+		})
+	}
+}
+
+func TestAllocateInvalidArguments(t *testing.T) {
+	h := newTestHeap(t, "contiguous", 16)
+	if _, err := h.Allocate(0, "", 1); !errors.Is(err, ErrInvalidSize) {
+		t.Errorf("Allocate(0): got %v, want ErrInvalidSize", err)
+	}
+	if _, err := h.Allocate(1, "", 0); !errors.Is(err, ErrAlignment) {
+		t.Errorf("Allocate with align 0: got %v, want ErrAlignment", err)
+	}
+	buddy := newTestHeap(t, "buddy", 16)
+	if _, err := buddy.Allocate(1, "", 3); !errors.Is(err, ErrAlignment) {
+		t.Errorf("buddy Allocate with align 3: got %v, want ErrAlignment", err)
+	}
+}
+
+func TestFreeErrors(t *testing.T) {
+	for _, mode := range []string{"contiguous", "buddy", "slab", "managed"} {
+		t.Run(mode, func(t *testing.T) {
+			h := newTestHeap(t, mode, 64)
+			id := mustAllocate(t, h, 4, "", 1)
+			other := mustAllocate(t, h, 4, "", 1)
+
+			var blockErr *BlockError
+			if err := h.Free(63); !errors.Is(err, ErrInvalidBlock) || !errors.As(err, &blockErr) || blockErr.ID != 63 {
+				t.Errorf("Free(63) of no block: got %v, want block 63 ErrInvalidBlock", err)
+			}
+			if err := h.Free(id); err != nil {
+				t.Fatalf("Free(%d): %v", id, err)
+			}
+			if err := h.Free(id); !errors.Is(err, ErrDoubleFree) {
+				t.Errorf("second Free(%d): got %v, want ErrDoubleFree", id, err)
+			}
+			if _, ok := h.Block(other); !ok {
+				t.Errorf("double free of %d released block %d", id, other)
+			}
+		})
+	}
+}
+
+func TestReallocErrors(t *testing.T) {
+	for _, mode := range []string{"contiguous", "buddy", "slab", "managed"} {
+		t.Run(mode, func(t *testing.T) {
+			h := newTestHeap(t, mode, 64)
+			id := mustAllocate(t, h, 4, "", 1)
+
+			if _, err := h.Realloc(63, 8); !errors.Is(err, ErrInvalidBlock) {
+				t.Errorf("Realloc(63) of no block: got %v, want ErrInvalidBlock", err)
+			}
+			if _, err := h.Realloc(id, 0); !errors.Is(err, ErrInvalidSize) {
+				t.Errorf("Realloc(%d, 0): got %v, want ErrInvalidSize", id, err)
+			}
+			if _, err := h.Realloc(id, 65); !errors.Is(err, ErrOutOfMemory) {
+				t.Errorf("Realloc(%d, 65) on 64 cells: got %v, want ErrOutOfMemory", id, err)
+			}
+			if b, ok := h.Block(id); !ok || b.Size < 4 {
+				t.Errorf("failed Realloc lost block %d", id)
+			}
+			if err := h.Free(id); err != nil {
+				t.Fatalf("Free(%d): %v", id, err)
+			}
+			if _, err := h.Realloc(id, 8); !errors.Is(err, ErrInvalidBlock) {
+				t.Errorf("Realloc(%d) of a freed block: got %v, want ErrInvalidBlock", id, err)
+			}
+		})
+	}
+}
+
+func TestQuotaCountsRoundingAndPadding(t *testing.T) {
+	buddy := newTestHeap(t, "buddy", 16)
+	buddy.SetQuota("a", 5)
+	if _, err := buddy.Allocate(5, "a", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("buddy Allocate(5) rounded to 8 cells under a quota of 5: got %v, want ErrQuotaExceeded", err)
+	}
+
+	h := newTestHeap(t, "contiguous", 16)
+	h.SetQuota("a", 6)
+	mustAllocate(t, h, 1, "x", 1)
+	if _, err := h.Allocate(4, "a", 4); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Allocate(4) with 3 padding cells under a quota of 6: got %v, want ErrQuotaExceeded", err)
+	}
+	mustAllocate(t, h, 4, "a", 1)
+	for _, q := range h.Quotas() {
+		if q.Owner == "a" && q.Used != 4 {
+			t.Errorf("a uses %d cells, want 4", q.Used)
+		}
+	}
+}
+
+// checkInverse fails unless inverse holds exactly the pairs of m reversed.
+func checkInverse(t *testing.T, name string, m, inverse map[int]map[int]bool) {
+	t.Helper()
+	pairs := 0
+	for a, bs := range m {
+		for b := range bs {
+			if !inverse[b][a] {
+				t.Fatalf("%s is missing %d -> %d", name, b, a)
+			}
+			pairs++
+		}
+	}
+	for _, as := range inverse {
+		if len(as) == 0 {
+			t.Fatalf("%s keeps an empty set", name)
+		}
+		pairs -= len(as)
+	}
+	if pairs != 0 {
+		t.Fatalf("%s has %d pairs that are not in the map it inverts", name, -pairs)
+	}
+}
+
+func TestReverseIndexes(t *testing.T) {
+	h := newTestHeap(t, "managed", 512)
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 5000; i++ {
+		blocks := h.Blocks()
+		pick := func() int { return blocks[rng.Intn(len(blocks))].Start }
+		if len(blocks) > 0 && rng.Intn(3) == 0 {
+			h.Begin()
+			switch rng.Intn(4) {
+			case 0:
+				h.AddRef(pick(), pick())
+			case 1:
+				h.RemoveRef(pick(), pick())
+			case 2:
+				h.AddRoot(pick())
+			default:
+				h.CollectGarbage(rng.Intn(2) == 0)
+			}
+			h.Commit()
+		} else {
+			randomOp(rng, h)
+		}
+
+		relocated := make(map[int]map[int]bool)
+		for from, to := range h.relocated {
+			relocated[from] = map[int]bool{to: true}
+			if _, ok := h.blocks[to]; !ok {
+				t.Fatalf("old ID %d resolves to %d, which is not a live block", from, to)
+			}
+		}
+		checkInverse(t, "aliases", relocated, h.aliases)
+		checkInverse(t, "referrers", h.refs, h.referrers)
+		for from, targets := range h.refs {
+			for to := range targets {
+				if h.blocks[from] == nil || h.blocks[to] == nil {
+					t.Fatalf("reference %d -> %d outlived a block", from, to)
+				}
+			}
+		}
+	}
+}
+
+func TestCompactReportsQuotaOverrun(t *testing.T) {
+	h := newTestHeap(t, "contiguous", 16)
+	mustAllocate(t, h, 1, "x", 1)
+	y := mustAllocate(t, h, 3, "y", 1)
+	mustAllocate(t, h, 4, "a", 4)
+	h.SetQuota("a", 4)
+	if err := h.Free(y); err != nil {
+		t.Fatal(err)
+	}
+	h.Events()
+
+	if _, err := h.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	want := Event{Kind: QuotaOverrun, Quota: Quota{"a", 4, 7}}
+	if events := h.Events(); len(events) != 1 || events[0].Kind != want.Kind || events[0].Quota != want.Quota {
+		t.Errorf("events after Compact = %+v, want %+v", events, want)
+	}
+}
@@ -0,0 +1,299 @@
+package memsim
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var Policies = []string{"fifo", "lru", "clock", "optimal"}
+
+var (
+	ErrProcessExists  = errors.New("process already exists")
+	ErrUnknownProcess = errors.New("unknown process")
+	ErrSegfault       = errors.New("address outside the process")
+	// ErrOfflinePolicy is returned for optimal replacement, which needs
+	// future references and can only be evaluated by PageStats.
+	ErrOfflinePolicy = errors.New("policy cannot run live")
+)
+
+type process struct {
+	pid   int
+	pages []int // page number -> frame number, -1 when not resident
+}
+
+type frame struct {
+	pid, page  int
+	loadedAt   int
+	lastUsed   int
+	referenced bool
+}
+
+type pageRef struct {
+	pid, page int
+}
+
+func (h *Heap) pagingInit(memSize int) {
+	h.frames = make([]*frame, memSize/h.pageSize)
+}
+
+func (h *Heap) PageSize() int {
+	return h.pageSize
+}
+
+func (h *Heap) CreateProcess(pid, numPages int) error {
+	if h.mode != "paging" {
+		return unsupported(h.mode)
+	}
+	if numPages <= 0 {
+		return ErrInvalidSize
+	}
+	if _, ok := h.processes[pid]; ok {
+		return ErrProcessExists
+	}
+	p := &process{pid: pid, pages: make([]int, numPages)}
+	for i := range p.pages {
+		p.pages[i] = -1
+	}
+	h.processes[pid] = p
+	return nil
+}
+
+// Access is the outcome of one memory reference. When Fault is set the page
+// was loaded into Frame, evicting EvictedPID's page EvictedPage if Evicted.
+type Access struct {
+	Page        int
+	Frame       int
+	Physical    int
+	Fault       bool
+	Evicted     bool
+	EvictedPID  int
+	EvictedPage int
+}
+
+func (h *Heap) Access(pid, addr int) (Access, error) {
+	if h.mode != "paging" {
+		return Access{}, unsupported(h.mode)
+	}
+	p, ok := h.processes[pid]
+	if !ok {
+		return Access{}, ErrUnknownProcess
+	}
+	page := addr / h.pageSize
+	if addr < 0 || page >= len(p.pages) {
+		return Access{}, ErrSegfault
+	}
+
+	h.clock++
+	h.references = append(h.references, pageRef{pid, page})
+
+	if f := p.pages[page]; f != -1 {
+		h.frames[f].lastUsed = h.clock
+		h.frames[f].referenced = true
+		return Access{Page: page, Frame: f, Physical: f*h.pageSize + addr%h.pageSize}, nil
+	}
+
+	h.pageFaults++
+	a := Access{Page: page, Fault: true}
+	f := h.freeFrame()
+	if f == -1 {
+		f = h.victimFrame()
+		victim := h.frames[f]
+		h.processes[victim.pid].pages[victim.page] = -1
+		h.clearCells(f*h.pageSize, h.pageSize)
+		delete(h.blocks, f*h.pageSize)
+		a.Evicted, a.EvictedPID, a.EvictedPage = true, victim.pid, victim.page
+	}
+
+	start := f * h.pageSize
+	h.fillCells(start, h.pageSize, start)
+	h.blocks[start] = &block{start: start, size: h.pageSize, requested: h.pageSize}
+	h.frames[f] = &frame{pid: pid, page: page, loadedAt: h.clock, lastUsed: h.clock, referenced: true}
+	p.pages[page] = f
+	a.Frame, a.Physical = f, start+addr%h.pageSize
+	return a, nil
+}
+
+func (h *Heap) freeFrame() int {
+	for i, f := range h.frames {
+		if f == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *Heap) victimFrame() int {
+	victim := 0
+	switch h.policy {
+	case "lru":
+		for i, f := range h.frames {
+			if f.lastUsed < h.frames[victim].lastUsed {
+				victim = i
+			}
+		}
+	case "clock":
+		for h.frames[h.clockHand].referenced {
+			h.frames[h.clockHand].referenced = false
+			h.clockHand = (h.clockHand + 1) % len(h.frames)
+		}
+		victim = h.clockHand
+		h.clockHand = (h.clockHand + 1) % len(h.frames)
+	default:
+		for i, f := range h.frames {
+			if f.loadedAt < h.frames[victim].loadedAt {
+				victim = i
+			}
+		}
+	}
+	return victim
+}
+
+// PageTable maps every page of a process to its frame, or -1 when the page
+// is not resident.
+func (h *Heap) PageTable(pid int) ([]int, error) {
+	if h.mode != "paging" {
+		return nil, unsupported(h.mode)
+	}
+	p, ok := h.processes[pid]
+	if !ok {
+		return nil, ErrUnknownProcess
+	}
+	return append([]int(nil), p.pages...), nil
+}
+
+type PageStats struct {
+	Frames     int
+	PageSize   int
+	References int
+	Policy     string
+	LiveFaults int
+	Faults     map[string]int // by policy
+}
+
+// PageStats replays the reference string recorded so far through every
+// replacement policy with the same number of frames. Optimal needs to know
+// future references, so it can only be evaluated this way.
+func (h *Heap) PageStats() PageStats {
+	s := PageStats{Frames: len(h.frames), PageSize: h.pageSize, References: len(h.references),
+		Policy: h.policy, LiveFaults: h.pageFaults, Faults: make(map[string]int, len(Policies))}
+	for _, name := range Policies {
+		s.Faults[name] = simulateReplacement(name, h.references, len(h.frames))
+	}
+	return s
+}
+
+func simulateReplacement(name string, refs []pageRef, numFrames int) int {
+	if numFrames == 0 {
+		return len(refs)
+	}
+	resident := make(map[pageRef]int) // page -> slot
+	slots := make([]pageRef, 0, numFrames)
+	loadedAt := make([]int, 0, numFrames)
+	lastUsed := make([]int, 0, numFrames)
+	referenced := make([]bool, 0, numFrames)
+	hand := 0
+	faults := 0
+
+	for t, ref := range refs {
+		if slot, ok := resident[ref]; ok {
+			lastUsed[slot] = t
+			referenced[slot] = true
+			continue
+		}
+		faults++
+		if len(slots) < numFrames {
+			resident[ref] = len(slots)
+			slots = append(slots, ref)
+			loadedAt = append(loadedAt, t)
+			lastUsed = append(lastUsed, t)
+			referenced = append(referenced, true)
+			continue
+		}
+
+		victim := 0
+		switch name {
+		case "fifo":
+			for i := range slots {
+				if loadedAt[i] < loadedAt[victim] {
+					victim = i
+				}
+			}
+		case "lru":
+			for i := range slots {
+				if lastUsed[i] < lastUsed[victim] {
+					victim = i
+				}
+			}
+		case "clock":
+			for referenced[hand] {
+				referenced[hand] = false
+				hand = (hand + 1) % numFrames
+			}
+			victim = hand
+			hand = (hand + 1) % numFrames
+		case "optimal":
+			farthest := -1
+			for i, page := range slots {
+				next := nextUse(refs, t+1, page)
+				if next > farthest {
+					farthest, victim = next, i
+				}
+			}
+		}
+
+		delete(resident, slots[victim])
+		resident[ref] = victim
+		slots[victim] = ref
+		loadedAt[victim] = t
+		lastUsed[victim] = t
+		referenced[victim] = true
+	}
+	return faults
+}
+
+func nextUse(refs []pageRef, from int, page pageRef) int {
+	for i := from; i < len(refs); i++ {
+		if refs[i] == page {
+			return i
+		}
+	}
+	return len(refs)
+}
+
+func (h *Heap) Policy() string {
+	return h.policy
+}
+
+func (h *Heap) SetPolicy(name string) error {
+	switch name {
+	case "fifo", "lru", "clock":
+		h.policy = name
+		return nil
+	case "optimal":
+		return ErrOfflinePolicy
+	}
+	return fmt.Errorf("unknown policy %q (available: %s)", name, strings.Join(Policies, ", "))
+}
+
+type ProcessInfo struct {
+	PID      int
+	Pages    int
+	Resident int
+}
+
+func (h *Heap) Processes() []ProcessInfo {
+	var procs []ProcessInfo
+	for pid, p := range h.processes {
+		info := ProcessInfo{PID: pid, Pages: len(p.pages)}
+		for _, f := range p.pages {
+			if f != -1 {
+				info.Resident++
+			}
+		}
+		procs = append(procs, info)
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs
+}
@@ -0,0 +1,204 @@
+package memsim
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaError reports that Owner, using Used of Limit cells, needed Extra
+// more cells, after rounding and padding, than its quota allows.
+type QuotaError struct {
+	Owner              string
+	Used, Limit, Extra int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: %s uses %d of %d cells", ErrQuotaExceeded, e.Owner, e.Used, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+type Pressure int
+
+const (
+	PressureNormal   Pressure = iota
+	PressureWarning           // usage at or above the low watermark
+	PressureCritical          // usage at or above the high watermark
+)
+
+type EventKind int
+
+const (
+	PressureChanged EventKind = iota
+	OOMKilled
+	QuotaOverrun
+)
+
+// Event reports a change of memory pressure, with the new level and the
+// share of memory in use, an owner whose blocks the OOM killer freed, or the
+// quota of an owner that compaction pushed over its limit.
+type Event struct {
+	Kind     EventKind
+	Pressure Pressure
+	UsedPct  int
+	Victim   OwnerUsage
+	Quota    Quota
+}
+
+type Quota struct {
+	Owner string
+	Limit int
+	Used  int
+}
+
+// SetQuota limits the cells the blocks of owner may occupy. A limit of 0
+// removes the quota.
+func (h *Heap) SetQuota(owner string, cells int) {
+	if cells <= 0 {
+		deleteKey(h, h.quotas, owner)
+	} else if h.quotas[owner] != cells {
+		setKey(h, h.quotas, owner, cells)
+	}
+}
+
+func (h *Heap) Quotas() []Quota {
+	quotas := make([]Quota, 0, len(h.quotas))
+	for owner, limit := range h.quotas {
+		quotas = append(quotas, Quota{owner, limit, h.usage(owner)})
+	}
+	sort.Slice(quotas, func(i, j int) bool { return quotas[i].Owner < quotas[j].Owner })
+	return quotas
+}
+
+func (h *Heap) usage(owner string) int {
+	cells := 0
+	for _, b := range h.blocks {
+		if b.owner == owner && !b.isSlab() {
+			cells += b.cells()
+		}
+	}
+	return cells
+}
+
+// checkQuota fails if owner would go over quota by growing by extra cells.
+func (h *Heap) checkQuota(owner string, extra int) error {
+	limit, ok := h.quotas[owner]
+	if !ok {
+		return nil
+	}
+	if used := h.usage(owner); used+extra > limit {
+		return &QuotaError{owner, used, limit, extra}
+	}
+	return nil
+}
+
+// SetWatermarks sets the usage percentages at which memory pressure becomes
+// a warning and critical. 0 disables a watermark.
+func (h *Heap) SetWatermarks(low, high int) error {
+	if err := checkWatermarks(low, high); err != nil {
+		return err
+	}
+	if low != h.lowWatermark {
+		swap(h, &h.lowWatermark, low)
+	}
+	if high != h.highWatermark {
+		swap(h, &h.highWatermark, high)
+	}
+	h.checkPressure()
+	return nil
+}
+
+func checkWatermarks(low, high int) error {
+	if low < 0 || high < 0 || low > 100 || high > 100 || high > 0 && low > high {
+		return errors.New("watermarks must be percentages with low <= high")
+	}
+	return nil
+}
+
+func (h *Heap) Watermarks() (low, high int) {
+	return h.lowWatermark, h.highWatermark
+}
+
+func (h *Heap) usedPct() int {
+	if len(h.memory) == 0 {
+		return 0
+	}
+	return (len(h.memory) - h.freeIdx.free) * 100 / len(h.memory)
+}
+
+// checkPressure records an event when usage crossed a watermark since the
+// last check.
+func (h *Heap) checkPressure() {
+	used := h.usedPct()
+	level := PressureNormal
+	switch {
+	case h.highWatermark > 0 && used >= h.highWatermark:
+		level = PressureCritical
+	case h.lowWatermark > 0 && used >= h.lowWatermark:
+		level = PressureWarning
+	}
+	if level != h.pressure {
+		h.pressure = level
+		h.events = append(h.events, Event{Kind: PressureChanged, Pressure: level, UsedPct: used})
+	}
+}
+
+// SetOOMKiller makes allocations that do not fit free the blocks of the
+// owner holding the most memory, and retry, until they fit or no other
+// tagged owner is left.
+func (h *Heap) SetOOMKiller(on bool) {
+	if on != h.oomKiller {
+		swap(h, &h.oomKiller, on)
+	}
+}
+
+func (h *Heap) OOMKiller() bool {
+	return h.oomKiller
+}
+
+// killLargestOwner frees every block of the tagged owner, other than
+// except, that occupies the most cells. It kills nobody when the OOM killer
+// is off or when numCells would not fit even if every other tagged owner was
+// gone.
+func (h *Heap) killLargestOwner(except string, numCells int) bool {
+	if !h.oomKiller {
+		return false
+	}
+	kept := h.usage(except)
+	if except != "" {
+		kept += h.usage("")
+	}
+	if numCells > len(h.memory)-kept {
+		return false
+	}
+
+	var victim *OwnerUsage
+	owners := h.Owners()
+	for i := range owners {
+		u := &owners[i]
+		if u.Owner != "" && u.Owner != except && (victim == nil || u.Cells > victim.Cells) {
+			victim = u
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	for _, id := range victim.Blocks {
+		h.freeBlock(h.blocks[id])
+	}
+	h.events = append(h.events, Event{Kind: OOMKilled, Victim: *victim})
+	return true
+}
+
+// Events returns what happened since the last call: pressure changes and OOM
+// kills, oldest first.
+func (h *Heap) Events() []Event {
+	events := h.events
+	h.events = nil
+	return events
+}
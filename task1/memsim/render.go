@@ -0,0 +1,218 @@
+package memsim
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var Formats = []string{"text", "ansi", "svg"}
+
+type cellKind int
+
+const (
+	freeCell cellKind = iota
+	headCell          // first used cell of a block within a row
+	bodyCell
+	padCell // alignment padding or the unrequested tail of a block
+)
+
+type mapCell struct {
+	kind cellKind
+	id   int
+}
+
+// memoryMap is the heap laid out in rows of width cells, ready for a renderer.
+// idWidth is the number of digits in the largest block ID, and colors gives
+// every block its index in address order so neighbours never share a colour.
+type memoryMap struct {
+	rows    [][]mapCell
+	orders  [][]string // buddy order notes per row
+	mode    string
+	width   int
+	idWidth int
+	colors  map[int]int
+	blocks  map[int]*block
+}
+
+type renderer interface {
+	render(w io.Writer, m memoryMap) error
+}
+
+type textRenderer struct{}
+
+type ansiRenderer struct{}
+
+type svgRenderer struct{}
+
+func newRenderer(name string) (renderer, error) {
+	switch name {
+	case "text":
+		return textRenderer{}, nil
+	case "ansi":
+		return ansiRenderer{}, nil
+	case "svg":
+		return svgRenderer{}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (available: %s)", name, strings.Join(Formats, ", "))
+}
+
+func (h *Heap) memoryMap() memoryMap {
+	m := memoryMap{mode: h.mode, width: h.width, idWidth: 1, colors: make(map[int]int, len(h.blocks)), blocks: h.blocks}
+	starts := make([]int, 0, len(h.blocks))
+	for start := range h.blocks {
+		starts = append(starts, start)
+		m.idWidth = max(m.idWidth, len(strconv.Itoa(start)))
+	}
+	sort.Ints(starts)
+	for i, start := range starts {
+		m.colors[start] = i
+	}
+
+	for i := 0; i < len(h.memory); i += h.width {
+		end := min(i+h.width, len(h.memory))
+		row := make([]mapCell, 0, end-i)
+		var orders []string
+		currentBlock := -1
+		for j := i; j < end; j++ {
+			id := h.memory[j]
+			if id == -1 {
+				row = append(row, mapCell{freeCell, -1})
+				continue
+			}
+			b := h.blocks[id]
+			if j == b.start && h.mode == "buddy" {
+				orders = append(orders, fmt.Sprintf("%d(o%d)", b.start, b.order))
+			}
+			switch {
+			case j < b.start || j >= b.start+b.requested:
+				row = append(row, mapCell{padCell, id})
+			case id != currentBlock:
+				currentBlock = id
+				row = append(row, mapCell{headCell, id})
+			default:
+				row = append(row, mapCell{bodyCell, id})
+			}
+		}
+		m.rows = append(m.rows, row)
+		m.orders = append(m.orders, orders)
+	}
+	return m
+}
+
+// text returns a cell padded to idWidth characters. With single-digit IDs it
+// is the classic one character per cell map.
+func (m memoryMap) text(c mapCell) string {
+	switch c.kind {
+	case freeCell:
+		return strings.Repeat(" ", m.idWidth)
+	case padCell:
+		return strings.Repeat(".", m.idWidth)
+	case headCell:
+		id := strconv.Itoa(c.id)
+		return id + strings.Repeat("x", m.idWidth-len(id))
+	}
+	return strings.Repeat("x", m.idWidth)
+}
+
+func (textRenderer) render(w io.Writer, m memoryMap) error {
+	return m.renderRows(w, func(c mapCell) string { return m.text(c) })
+}
+
+var ansiColors = []int{41, 42, 43, 44, 45, 46, 101, 102, 103, 104, 105, 106}
+
+func (ansiRenderer) render(w io.Writer, m memoryMap) error {
+	return m.renderRows(w, func(c mapCell) string {
+		if c.kind == freeCell {
+			return m.text(c)
+		}
+		color := ansiColors[m.colors[c.id]%len(ansiColors)]
+		return fmt.Sprintf("\x1b[30;%dm%s\x1b[0m", color, m.text(c))
+	})
+}
+
+func (m memoryMap) renderRows(w io.Writer, cell func(mapCell) string) error {
+	for i, row := range m.rows {
+		var line strings.Builder
+		line.WriteString("|")
+		for _, c := range row {
+			line.WriteString(cell(c))
+		}
+		line.WriteString("|")
+		if len(m.orders[i]) > 0 {
+			line.WriteString("  " + strings.Join(m.orders[i], " "))
+		}
+		if _, err := fmt.Fprintln(w, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const svgCellSize = 24
+
+// svgColors follow the order of ansiColors so that both outputs of the same
+// heap look alike.
+var svgColors = []string{"#e06c75", "#98c379", "#e5c07b", "#61afef", "#c678dd", "#56b6c2",
+	"#ff8f8f", "#b5f58b", "#ffe08a", "#8ec7ff", "#e3a6f5", "#8ee6ef"}
+
+func (svgRenderer) render(w io.Writer, m memoryMap) error {
+	var out strings.Builder
+	width, height := m.width*svgCellSize, len(m.rows)*svgCellSize
+	fmt.Fprintf(&out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="%d">`+"\n",
+		width, height, width, height, svgCellSize/2)
+	for r, row := range m.rows {
+		for c, cell := range row {
+			x, y := c*svgCellSize, r*svgCellSize
+			fill, opacity := "#ffffff", 1.0
+			if cell.kind != freeCell {
+				fill = svgColors[m.colors[cell.id]%len(svgColors)]
+			}
+			if cell.kind == padCell {
+				opacity = 0.35
+			}
+			fmt.Fprintf(&out, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" fill-opacity="%.2f" stroke="#888888">`,
+				x, y, svgCellSize, svgCellSize, fill, opacity)
+			if cell.kind != freeCell {
+				fmt.Fprintf(&out, "<title>%s</title>", m.describe(cell.id))
+			}
+			out.WriteString("</rect>\n")
+			if cell.kind == headCell {
+				fmt.Fprintf(&out, `<text x="%d" y="%d" text-anchor="middle" dominant-baseline="central">%d</text>`+"\n",
+					x+svgCellSize/2, y+svgCellSize/2, cell.id)
+			}
+		}
+	}
+	out.WriteString("</svg>\n")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func (m memoryMap) describe(id int) string {
+	b := m.blocks[id]
+	desc := fmt.Sprintf("block %d: %d of %d cells", id, b.requested, b.size)
+	if m.mode == "buddy" {
+		desc += fmt.Sprintf(", order %d", b.order)
+	}
+	if b.owner != "" {
+		desc += ", owner " + escapeXML(b.owner)
+	}
+	return desc
+}
+
+func escapeXML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+// Render writes the memory map in one of Formats: text pads every cell to the
+// widest block ID, ansi also colours every block and svg draws the map as an
+// image.
+func (h *Heap) Render(w io.Writer, format string) error {
+	r, err := newRenderer(format)
+	if err != nil {
+		return err
+	}
+	return r.render(w, h.memoryMap())
+}
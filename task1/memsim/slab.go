@@ -0,0 +1,127 @@
+package memsim
+
+import "sort"
+
+// Each slab is one header cell followed by slabCells object cells, split
+// into equal slots of its size class. Requests larger than the biggest class
+// are placed directly in memory by the active strategy.
+const slabCells = 16
+
+var slabClasses = []int{1, 2, 4, 8, 16}
+
+type slab struct {
+	start int // header cell, also the slab's block ID
+	class int
+	free  []int // start cells of unused slots
+	used  int
+}
+
+func (b *block) isSlab() bool {
+	return b.slab != nil && b.slab.start == b.start
+}
+
+func sizeClass(numCells int) int {
+	for _, class := range slabClasses {
+		if numCells <= class {
+			return class
+		}
+	}
+	return -1
+}
+
+// slabTake serves numCells from a slab of the matching size class, carving a
+// new slab out of free memory when every slab of that class is full.
+func (h *Heap) slabTake(numCells int, owner string) int {
+	class := sizeClass(numCells)
+	var s *slab
+	for _, candidate := range h.slabsByClass[class] {
+		if len(candidate.free) > 0 {
+			s = candidate
+			break
+		}
+	}
+	if s == nil {
+		first, start := h.locate(1+slabCells, 1)
+		if first == -1 {
+			return -1
+		}
+		h.placeBlock(first, start, 1+slabCells)
+		s = &slab{start: start, class: class}
+		for slot := start + 1; slot+class <= start+1+slabCells; slot += class {
+			s.free = append(s.free, slot)
+		}
+		h.addBlock(&block{start: start, size: 1 + slabCells, requested: 1, align: 1, slab: s})
+		h.slabsByClass[class] = append(h.slabsByClass[class], s)
+		sort.Slice(h.slabsByClass[class], func(i, j int) bool {
+			return h.slabsByClass[class][i].start < h.slabsByClass[class][j].start
+		})
+	}
+
+	id := s.free[0]
+	s.free = s.free[1:]
+	s.used++
+	for i := id; i < id+class; i++ {
+		h.memory[i] = id
+	}
+	h.addBlock(&block{start: id, size: class, requested: numCells, align: 1, owner: owner, slab: s})
+	return id
+}
+
+func (h *Heap) slabFree(b *block) {
+	s := b.slab
+	for i := b.start; i < b.start+b.size; i++ {
+		h.memory[i] = s.start
+	}
+	delete(h.blocks, b.start)
+	s.used--
+	s.free = append(s.free, b.start)
+	sort.Ints(s.free)
+
+	if s.used == 0 {
+		h.clearCells(s.start, 1+slabCells)
+		delete(h.blocks, s.start)
+		list := h.slabsByClass[s.class]
+		for i := range list {
+			if list[i] == s {
+				h.slabsByClass[s.class] = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// slabRealloc keeps an object in its slot while the new size fits its size
+// class and otherwise moves it to a slab or region that fits.
+func (h *Heap) slabRealloc(b *block, newSize int) (int, error) {
+	if newSize <= b.size {
+		b.requested = newSize
+		return b.start, nil
+	}
+	start, err := h.allocateBlock(newSize, b.owner, 1, b.cells())
+	if err != nil {
+		return -1, err
+	}
+	h.freeBlock(b)
+	return start, nil
+}
+
+type SlabClass struct {
+	Class    int
+	Slabs    int
+	Objects  int
+	Capacity int
+}
+
+// Slabs reports the occupancy of every size class.
+func (h *Heap) Slabs() []SlabClass {
+	classes := make([]SlabClass, len(slabClasses))
+	for i, class := range slabClasses {
+		list := h.slabsByClass[class]
+		classes[i] = SlabClass{Class: class, Slabs: len(list)}
+		for _, s := range list {
+			classes[i].Objects += s.used
+			classes[i].Capacity += slabCells / class
+		}
+	}
+	return classes
+}
@@ -0,0 +1,249 @@
+package memsim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+)
+
+type blockState struct {
+	Start     int    `json:"start"`
+	Size      int    `json:"size"`
+	Requested int    `json:"requested"`
+	Order     int    `json:"order,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Pad       int    `json:"pad,omitempty"`
+	Align     int    `json:"align,omitempty"`
+}
+
+// Snapshot is the full state of a heap in contiguous, buddy or managed mode,
+// including its quotas, watermarks and OOM killer setting. It marshals to the
+// JSON that Save writes.
+type Snapshot struct {
+	Mode           string         `json:"mode"`
+	Strategy       string         `json:"strategy"`
+	NextFitCursor  int            `json:"next_fit_cursor,omitempty"`
+	Width          int            `json:"width"`
+	Memory         []int          `json:"memory"`
+	Blocks         []blockState   `json:"blocks"`
+	BuddyFreeLists [][]int        `json:"buddy_free_lists,omitempty"`
+	Relocated      map[int]int    `json:"relocated,omitempty"`
+	Freed          []int          `json:"freed,omitempty"`
+	Refs           map[int][]int  `json:"refs,omitempty"`
+	Roots          []int          `json:"roots,omitempty"`
+	Quotas         map[string]int `json:"quotas,omitempty"`
+	LowWatermark   int            `json:"low_watermark,omitempty"`
+	HighWatermark  int            `json:"high_watermark,omitempty"`
+	OOMKiller      bool           `json:"oom_killer,omitempty"`
+}
+
+// Slabs and page tables are not part of a snapshot, so their modes cannot be
+// saved or undone.
+var snapshotModes = []string{"contiguous", "buddy", "managed"}
+
+func (h *Heap) SnapshotsSupported() bool {
+	return slices.Contains(snapshotModes, h.mode)
+}
+
+func (h *Heap) Snapshot() Snapshot {
+	s := Snapshot{
+		Mode:      h.mode,
+		Strategy:  h.allocator.Name(),
+		Width:     h.width,
+		Memory:    append([]int(nil), h.memory...),
+		Blocks:    make([]blockState, 0, len(h.blocks)),
+		Relocated: make(map[int]int, len(h.relocated)),
+	}
+	if a, ok := h.allocator.(*nextFit); ok {
+		s.NextFitCursor = a.last
+	}
+	for _, b := range h.blocks {
+		s.Blocks = append(s.Blocks, blockState{b.start, b.size, b.requested, b.order, b.owner, b.pad, b.align})
+	}
+	sort.Slice(s.Blocks, func(i, j int) bool { return s.Blocks[i].Start < s.Blocks[j].Start })
+	for _, list := range h.buddyFreeLists {
+		s.BuddyFreeLists = append(s.BuddyFreeLists, append([]int{}, list...))
+	}
+	for from, to := range h.relocated {
+		s.Relocated[from] = to
+	}
+	for id := range h.freed {
+		s.Freed = append(s.Freed, id)
+	}
+	sort.Ints(s.Freed)
+	for from, targets := range h.refs {
+		if s.Refs == nil {
+			s.Refs = make(map[int][]int, len(h.refs))
+		}
+		for to := range targets {
+			s.Refs[from] = append(s.Refs[from], to)
+		}
+		sort.Ints(s.Refs[from])
+	}
+	for id := range h.roots {
+		s.Roots = append(s.Roots, id)
+	}
+	sort.Ints(s.Roots)
+	for owner, limit := range h.quotas {
+		if s.Quotas == nil {
+			s.Quotas = make(map[string]int, len(h.quotas))
+		}
+		s.Quotas[owner] = limit
+	}
+	s.LowWatermark, s.HighWatermark, s.OOMKiller = h.lowWatermark, h.highWatermark, h.oomKiller
+	return s
+}
+
+func (h *Heap) Restore(s Snapshot) error {
+	if !h.SnapshotsSupported() {
+		return unsupported(h.mode)
+	}
+	if !slices.Contains(snapshotModes, s.Mode) {
+		return fmt.Errorf("unknown mode %q", s.Mode)
+	}
+	if s.Width <= 0 {
+		return errors.New("invalid output width")
+	}
+	if len(s.Memory) == 0 {
+		return errors.New("snapshot has no memory")
+	}
+	if err := checkWatermarks(s.LowWatermark, s.HighWatermark); err != nil {
+		return err
+	}
+	a, err := newAllocator(s.Strategy)
+	if err != nil {
+		return err
+	}
+	if next, ok := a.(*nextFit); ok {
+		next.last = s.NextFitCursor
+	}
+
+	restored := make(map[int]*block, len(s.Blocks))
+	for _, b := range s.Blocks {
+		if b.Pad < 0 || b.Start-b.Pad < 0 || b.Size <= 0 || b.Start+b.Size > len(s.Memory) {
+			return fmt.Errorf("block %d is out of memory bounds", b.Start)
+		}
+		restored[b.Start] = &block{start: b.Start, size: b.Size, requested: b.Requested, order: b.Order,
+			owner: b.Owner, pad: b.Pad, align: max(b.Align, 1)}
+	}
+	for i, id := range s.Memory {
+		if b, ok := restored[id]; id != -1 && (!ok || i < b.first() || i >= b.start+b.size) {
+			return fmt.Errorf("cell %d does not belong to a known block", i)
+		}
+	}
+
+	state := heapState{
+		mode:      s.Mode,
+		allocator: a,
+		width:     s.Width,
+		memory:    append([]int(nil), s.Memory...),
+		blocks:    restored,
+		relocated: make(map[int]int, len(s.Relocated)),
+		aliases:   make(map[int]map[int]bool),
+		freed:     make(map[int]bool, len(s.Freed)),
+		refs:      make(map[int]map[int]bool, len(s.Refs)),
+		referrers: make(map[int]map[int]bool),
+		roots:     make(map[int]bool, len(s.Roots)),
+		quotas:    make(map[string]int, len(s.Quotas)),
+		low:       s.LowWatermark,
+		high:      s.HighWatermark,
+		oomKiller: s.OOMKiller,
+	}
+	if s.Mode == "buddy" {
+		// The free lists follow from the blocks, so the saved ones are not
+		// trusted: a stale list would make every allocation fail.
+		if state.buddyFreeLists, err = buddyFreeListsFor(state.memory, restored); err != nil {
+			return err
+		}
+	}
+	for from, to := range s.Relocated {
+		state.relocated[from] = to
+		if state.aliases[to] == nil {
+			state.aliases[to] = make(map[int]bool)
+		}
+		state.aliases[to][from] = true
+	}
+	for _, id := range s.Freed {
+		state.freed[id] = true
+	}
+	for from, targets := range s.Refs {
+		state.refs[from] = make(map[int]bool, len(targets))
+		for _, to := range targets {
+			state.refs[from][to] = true
+			if state.referrers[to] == nil {
+				state.referrers[to] = make(map[int]bool)
+			}
+			state.referrers[to][from] = true
+		}
+	}
+	for _, id := range s.Roots {
+		state.roots[id] = true
+	}
+	for owner, limit := range s.Quotas {
+		if limit <= 0 {
+			return fmt.Errorf("invalid quota %d for %q", limit, owner)
+		}
+		state.quotas[owner] = limit
+	}
+	h.replaceState(state)
+	h.checkPressure()
+	return nil
+}
+
+// heapState is everything a snapshot restores.
+type heapState struct {
+	mode           string
+	allocator      allocator
+	width          int
+	memory         []int
+	blocks         map[int]*block
+	buddyFreeLists [][]int
+	relocated      map[int]int
+	aliases        map[int]map[int]bool
+	freed          map[int]bool
+	refs           map[int]map[int]bool
+	referrers      map[int]map[int]bool
+	roots          map[int]bool
+	quotas         map[string]int
+	low, high      int
+	oomKiller      bool
+}
+
+// replaceState installs s and logs putting the current state back.
+func (h *Heap) replaceState(s heapState) {
+	old := heapState{h.mode, h.allocator, h.width, h.memory, h.blocks, h.buddyFreeLists,
+		h.relocated, h.aliases, h.freed, h.refs, h.referrers, h.roots,
+		h.quotas, h.lowWatermark, h.highWatermark, h.oomKiller}
+	h.logUndo(func() { h.replaceState(old) })
+	h.mode, h.allocator, h.width, h.memory, h.blocks = s.mode, s.allocator, s.width, s.memory, s.blocks
+	h.buddyFreeLists, h.relocated, h.aliases, h.freed = s.buddyFreeLists, s.relocated, s.aliases, s.freed
+	h.refs, h.referrers, h.roots = s.refs, s.referrers, s.roots
+	h.quotas, h.lowWatermark, h.highWatermark, h.oomKiller = s.quotas, s.low, s.high, s.oomKiller
+	h.freeIdx.rebuild(h.memory)
+}
+
+func (h *Heap) Save(filename string) error {
+	if !h.SnapshotsSupported() {
+		return unsupported(h.mode)
+	}
+	data, err := json.MarshalIndent(h.Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+func (h *Heap) Load(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return h.Restore(s)
+}
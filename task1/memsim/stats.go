@@ -0,0 +1,44 @@
+package memsim
+
+type Stats struct {
+	Used          int
+	Free          int
+	Holes         int
+	LargestHole   int
+	Fragmentation float64
+	LiveBlocks    int
+	AvgBlockSize  float64
+	Padding       int
+}
+
+func (h *Heap) Stats() Stats {
+	var s Stats
+	for _, gap := range h.freeIdx.holes() {
+		s.Free += gap.size
+		s.Holes++
+		s.LargestHole = max(s.LargestHole, gap.size)
+	}
+	s.Used = len(h.memory) - s.Free
+
+	// External fragmentation: the share of free memory that cannot be
+	// handed out as a single block.
+	if s.Free > 0 {
+		s.Fragmentation = 1 - float64(s.LargestHole)/float64(s.Free)
+	}
+
+	blockCells := 0
+	for _, b := range h.blocks {
+		if b.isSlab() {
+			// The header is bookkeeping; unused slots are slab waste.
+			s.Padding += len(b.slab.free) * b.slab.class
+			continue
+		}
+		s.LiveBlocks++
+		blockCells += b.cells()
+		s.Padding += b.pad + b.size - b.requested
+	}
+	if s.LiveBlocks > 0 {
+		s.AvgBlockSize = float64(blockCells) / float64(s.LiveBlocks)
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package memsim
+
+import "slices"
+
+const maxHistory = 100
+
+// change is the undo log of one operation: the steps that revert it, in the
+// order they were recorded. Every step logs its own inverse when it runs, so
+// undoing a change records the change that redoes it.
+type change []func()
+
+// Begin starts recording the changes made to the heap until Commit, so they
+// can be undone as one step. Heaps that do not support snapshots record
+// nothing.
+func (h *Heap) Begin() {
+	if !h.SnapshotsSupported() {
+		return
+	}
+	h.recording, h.journal = true, nil
+}
+
+// Commit ends the recording started by Begin and reports whether the heap
+// changed. A change goes onto the undo stack and clears the redo stack.
+func (h *Heap) Commit() bool {
+	if !h.recording {
+		return false
+	}
+	c := h.journal
+	h.recording, h.journal = false, nil
+	if len(c) == 0 {
+		return false
+	}
+	h.undoStack = append(h.undoStack, c)
+	if len(h.undoStack) > maxHistory {
+		h.undoStack = h.undoStack[1:]
+	}
+	h.redoStack = nil
+	return true
+}
+
+func (h *Heap) Undo() bool {
+	if len(h.undoStack) == 0 {
+		return false
+	}
+	c := h.undoStack[len(h.undoStack)-1]
+	h.undoStack = h.undoStack[:len(h.undoStack)-1]
+	h.redoStack = append(h.redoStack, h.revert(c))
+	return true
+}
+
+func (h *Heap) Redo() bool {
+	if len(h.redoStack) == 0 {
+		return false
+	}
+	c := h.redoStack[len(h.redoStack)-1]
+	h.redoStack = h.redoStack[:len(h.redoStack)-1]
+	h.undoStack = append(h.undoStack, h.revert(c))
+	return true
+}
+
+// revert runs the steps of c from last to first and returns the change that
+// reverts them again.
+func (h *Heap) revert(c change) change {
+	h.recording, h.journal = true, nil
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i]()
+	}
+	inverse := h.journal
+	h.recording, h.journal = false, nil
+	h.checkPressure()
+	return inverse
+}
+
+func (h *Heap) logUndo(step func()) {
+	if h.recording {
+		h.journal = append(h.journal, step)
+	}
+}
+
+// swap sets *field to value and logs setting it back. Steps refer to the
+// maps, slices and blocks they change, so state is always replaced through
+// swap and never rebuilt, and undoing an earlier step finds the very objects
+// it changed.
+func swap[T any](h *Heap, field *T, value T) {
+	if h.recording {
+		old := *field
+		h.logUndo(func() { swap(h, field, old) })
+	}
+	*field = value
+}
+
+func setKey[K comparable, V any](h *Heap, m map[K]V, key K, value V) {
+	if h.recording {
+		if old, ok := m[key]; ok {
+			h.logUndo(func() { setKey(h, m, key, old) })
+		} else {
+			h.logUndo(func() { deleteKey(h, m, key) })
+		}
+	}
+	m[key] = value
+}
+
+func deleteKey[K comparable, V any](h *Heap, m map[K]V, key K) {
+	old, ok := m[key]
+	if !ok {
+		return
+	}
+	if h.recording {
+		h.logUndo(func() { setKey(h, m, key, old) })
+	}
+	delete(m, key)
+}
+
+// link and unlink add and remove the pair (a, b) in m, a set of pairs kept
+// as a set of bs for every a. An a without pairs has no entry.
+func link(h *Heap, m map[int]map[int]bool, a, b int) {
+	if m[a] == nil {
+		setKey(h, m, a, make(map[int]bool))
+	}
+	setKey(h, m[a], b, true)
+}
+
+func unlink(h *Heap, m map[int]map[int]bool, a, b int) {
+	deleteKey(h, m[a], b)
+	if len(m[a]) == 0 {
+		deleteKey(h, m, a)
+	}
+}
+
+// saveBlock logs restoring the fields of b, which the caller is about to
+// change.
+func (h *Heap) saveBlock(b *block) {
+	if h.recording {
+		old := *b
+		h.logUndo(func() { swap(h, b, old) })
+	}
+}
+
+// saveCells logs restoring cells [start, start+size), which the caller is
+// about to change.
+func (h *Heap) saveCells(start, size int) {
+	if h.recording && size > 0 {
+		old := slices.Clone(h.memory[start : start+size])
+		h.logUndo(func() { h.writeCells(start, old) })
+	}
+}
+
+// writeCells copies values into memory from cell start on. Only the runs of
+// cells that differ are logged, and the free index is updated for the cells
+// that become used or free.
+func (h *Heap) writeCells(start int, values []int) {
+	for i := 0; i < len(values); {
+		if h.memory[start+i] == values[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(values) && h.memory[start+j] != values[j] {
+			j++
+		}
+		h.saveCells(start+i, j-i)
+		for k := i; k < j; {
+			wasFree, free := h.memory[start+k] == -1, values[k] == -1
+			l := k
+			for l < j && (h.memory[start+l] == -1) == wasFree && (values[l] == -1) == free {
+				l++
+			}
+			if free && !wasFree {
+				h.freeIdx.release(start+k, l-k)
+			} else if wasFree && !free {
+				h.freeIdx.take(start+k, l-k)
+			}
+			k = l
+		}
+		copy(h.memory[start+i:start+j], values[i:j])
+		i = j
+	}
+}
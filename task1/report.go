@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/YaremaTymchyshyn/go-coding-4/task1/memsim"
+)
+
+func printStats(h *memsim.Heap) {
+	s := h.Stats()
+	fmt.Printf("Used cells:       %d\n", s.Used)
+	fmt.Printf("Free cells:       %d\n", s.Free)
+	fmt.Printf("Free holes:       %d\n", s.Holes)
+	fmt.Printf("Largest hole:     %d\n", s.LargestHole)
+	fmt.Printf("Fragmentation:    %.2f\n", s.Fragmentation)
+	fmt.Printf("Live blocks:      %d\n", s.LiveBlocks)
+	fmt.Printf("Avg block size:   %.2f\n", s.AvgBlockSize)
+	fmt.Printf("Padding cells:    %d\n", s.Padding)
+}
+
+func printSlabs(h *memsim.Heap) {
+	fmt.Println("class  slabs  objects  capacity  occupancy")
+	for _, c := range h.Slabs() {
+		occupancy := 0.0
+		if c.Capacity > 0 {
+			occupancy = 100 * float64(c.Objects) / float64(c.Capacity)
+		}
+		fmt.Printf("%-6d %-6d %-8d %-9d %.1f%%\n", c.Class, c.Slabs, c.Objects, c.Capacity, occupancy)
+	}
+}
+
+func printLeakReport(h *memsim.Heap) {
+	owners := h.Owners()
+	leaked, cells := 0, 0
+	for _, u := range owners {
+		leaked += len(u.Blocks)
+		cells += u.Cells
+	}
+	if leaked == 0 {
+		fmt.Println("No leaks.")
+		return
+	}
+
+	fmt.Printf("Leak report: %d blocks (%d cells) still allocated\n", leaked, cells)
+	for _, u := range owners {
+		name := u.Owner
+		if name == "" {
+			name = "(no owner)"
+		}
+		fmt.Printf("  %s: %d blocks, %d cells [%s]\n", name, len(u.Blocks), u.Cells, joinInts(u.Blocks))
+	}
+}
+
+func printRefs(h *memsim.Heap) {
+	fmt.Println("roots:", h.Roots())
+
+	refs := h.Refs()
+	froms := make([]int, 0, len(refs))
+	for from := range refs {
+		froms = append(froms, from)
+	}
+	sort.Ints(froms)
+	for _, from := range froms {
+		fmt.Printf("%d -> %v\n", from, refs[from])
+	}
+}
+
+func printPageTable(h *memsim.Heap, pid int) {
+	pages, err := h.PageTable(pid)
+	if err != nil {
+		fmt.Printf("Unknown process %d.\n", pid)
+		return
+	}
+	for page, f := range pages {
+		if f == -1 {
+			fmt.Printf("page %d -> -\n", page)
+		} else {
+			fmt.Printf("page %d -> frame %d\n", page, f)
+		}
+	}
+}
+
+func printPageStats(h *memsim.Heap) {
+	s := h.PageStats()
+	fmt.Printf("Frames: %d, page size: %d, references: %d\n", s.Frames, s.PageSize, s.References)
+	fmt.Printf("Live faults (%s): %d\n", s.Policy, s.LiveFaults)
+	for _, name := range memsim.Policies {
+		ratio := 0.0
+		if s.References > 0 {
+			ratio = float64(s.Faults[name]) / float64(s.References)
+		}
+		fmt.Printf("%-8s faults: %-6d fault rate: %.2f\n", name, s.Faults[name], ratio)
+	}
+}
+
+func printProcesses(h *memsim.Heap) {
+	for _, p := range h.Processes() {
+		fmt.Printf("process %d: %d pages, %d resident\n", p.PID, p.Pages, p.Resident)
+	}
+}
+
+func printGCError(err error) {
+	var be *memsim.BlockError
+	switch {
+	case err == nil:
+	case errors.As(err, &be) && be.Err == memsim.ErrNotRoot:
+		fmt.Printf("Block %d is not a root.\n", be.ID)
+	case errors.As(err, &be):
+		fmt.Printf("Block %d not found.\n", be.ID)
+	default:
+		fmt.Println(err)
+	}
+}
+
+func printQuotas(h *memsim.Heap) {
+	quotas := h.Quotas()
+	if len(quotas) == 0 {
+		fmt.Println("No quotas.")
+		return
+	}
+	for _, q := range quotas {
+		fmt.Printf("%s: %d of %d cells\n", q.Owner, q.Used, q.Limit)
+	}
+}
+
+func printQuotaExceeded(err error) {
+	var q *memsim.QuotaError
+	if errors.As(err, &q) {
+		fmt.Printf("Quota exceeded: %s uses %d of %d cells and asked for %d more.\n", q.Owner, q.Used, q.Limit, q.Extra)
+	}
+}
+
+func percentOrOff(pct int) string {
+	if pct == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d%%", pct)
+}
+
+func printEvents(h *memsim.Heap) {
+	for _, e := range h.Events() {
+		switch e.Kind {
+		case memsim.OOMKilled:
+			fmt.Printf("OOM killer: freed %d blocks (%d cells) of %s [%s]\n",
+				len(e.Victim.Blocks), e.Victim.Cells, e.Victim.Owner, joinInts(e.Victim.Blocks))
+			continue
+		case memsim.QuotaOverrun:
+			fmt.Printf("Quota overrun: compaction padded blocks of %s, which now uses %d of %d cells.\n",
+				e.Quota.Owner, e.Quota.Used, e.Quota.Limit)
+			continue
+		}
+		low, high := h.Watermarks()
+		switch e.Pressure {
+		case memsim.PressureCritical:
+			fmt.Printf("Memory pressure critical: %d%% used, at or above the high watermark (%d%%).\n", e.UsedPct, high)
+		case memsim.PressureWarning:
+			fmt.Printf("Memory pressure warning: %d%% used, at or above the low watermark (%d%%).\n", e.UsedPct, low)
+		default:
+			fmt.Printf("Memory pressure back to normal: %d%% used.\n", e.UsedPct)
+		}
+	}
+}
+
+func printRelocations(moved []memsim.Relocation) {
+	for _, r := range moved {
+		fmt.Printf("%d -> %d\n", r.From, r.To)
+	}
+}
+
+func joinInts(ids []int) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = fmt.Sprint(id)
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/YaremaTymchyshyn/go-coding-4/task1/memsim"
+)
+
+// AllocServer shares one heap between network clients. Every block a client
+// allocates is tagged with the client's name, only that client may free or
+// resize it, and whatever it still holds is freed when it disconnects.
+type AllocServer struct {
+	heap    *memsim.Heap
+	clients int
+	mu      sync.Mutex
+}
+
+type allocRequest struct {
+	Action string `json:"action"`
+	ID     int    `json:"id"`
+	Size   int    `json:"size"`
+	Align  int    `json:"align"`
+}
+
+func NewAllocServer(heap *memsim.Heap) *AllocServer {
+	return &AllocServer{heap: heap}
+}
+
+func (s *AllocServer) Connect() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients++
+	return fmt.Sprintf("client%d", s.clients)
+}
+
+func (s *AllocServer) Allocate(client string, size, align int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heap.Allocate(size, client, max(align, 1))
+}
+
+func (s *AllocServer) Free(client string, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOwner(client, id); err != nil {
+		return err
+	}
+	return s.heap.Free(id)
+}
+
+func (s *AllocServer) Realloc(client string, id, size int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkOwner(client, id); err != nil {
+		return -1, err
+	}
+	return s.heap.Realloc(id, size)
+}
+
+var errNotOwner = errors.New("block belongs to another client")
+
+func (s *AllocServer) checkOwner(client string, id int) error {
+	if b, ok := s.heap.Block(id); ok && b.Owner != client {
+		return errNotOwner
+	}
+	return nil
+}
+
+func (s *AllocServer) Blocks(client string) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.heap.Owners() {
+		if u.Owner == client {
+			return u.Blocks
+		}
+	}
+	return []int{}
+}
+
+func (s *AllocServer) Stats() memsim.Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heap.Stats()
+}
+
+func (s *AllocServer) Print() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out strings.Builder
+	s.heap.Render(&out, "text")
+	return out.String()
+}
+
+// Disconnect frees every block the client still holds and returns their IDs.
+func (s *AllocServer) Disconnect(client string) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reclaimed []int
+	for _, u := range s.heap.Owners() {
+		if u.Owner != client {
+			continue
+		}
+		for _, id := range u.Blocks {
+			s.heap.Free(id)
+		}
+		reclaimed = u.Blocks
+	}
+	return reclaimed
+}
+
+func handleAllocConnection(conn net.Conn, server *AllocServer) {
+	defer conn.Close()
+
+	client := server.Connect()
+	fmt.Printf("%s connected from %s\n", client, conn.RemoteAddr())
+	defer func() {
+		reclaimed := server.Disconnect(client)
+		fmt.Printf("%s disconnected, reclaimed %d blocks\n", client, len(reclaimed))
+	}()
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	encoder.Encode(map[string]string{"client": client})
+
+	for {
+		var request allocRequest
+		if err := decoder.Decode(&request); err != nil {
+			// A value of the wrong type is still read whole, so the
+			// stream stays usable and only this request fails.
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				encoder.Encode(map[string]string{"error": err.Error()})
+				continue
+			}
+			if err != io.EOF {
+				fmt.Printf("Error decoding request from %s: %v\n", client, err)
+			}
+			return
+		}
+
+		switch request.Action {
+		case "allocate":
+			id, err := server.Allocate(client, request.Size, request.Align)
+			if err != nil {
+				encoder.Encode(map[string]string{"error": err.Error()})
+				continue
+			}
+			encoder.Encode(map[string]int{"id": id})
+
+		case "free":
+			if err := server.Free(client, request.ID); err != nil {
+				encoder.Encode(map[string]string{"error": err.Error()})
+				continue
+			}
+			encoder.Encode(map[string]string{"status": "Block freed"})
+
+		case "realloc":
+			id, err := server.Realloc(client, request.ID, request.Size)
+			if err != nil {
+				encoder.Encode(map[string]string{"error": err.Error()})
+				continue
+			}
+			encoder.Encode(map[string]int{"id": id})
+
+		case "blocks":
+			encoder.Encode(map[string][]int{"blocks": server.Blocks(client)})
+
+		case "stats":
+			st := server.Stats()
+			encoder.Encode(map[string]interface{}{
+				"used": st.Used, "free": st.Free, "holes": st.Holes, "largest_hole": st.LargestHole,
+				"fragmentation": st.Fragmentation, "live_blocks": st.LiveBlocks,
+			})
+
+		case "print":
+			encoder.Encode(map[string]string{"map": server.Print()})
+
+		default:
+			encoder.Encode(map[string]string{"error": "Unknown action"})
+		}
+	}
+}
+
+func runServer(args []string) {
+	var cfg memsim.Config
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8081", "address to listen on")
+	fs.StringVar(&cfg.Strategy, "strategy", "first", "placement strategy: "+strings.Join(memsim.Strategies, ", "))
+	fs.StringVar(&cfg.Mode, "mode", "contiguous", "allocation mode: contiguous, buddy or slab")
+	fs.IntVar(&cfg.Size, "mem", 1024, "memory size in cells")
+	fs.IntVar(&cfg.Width, "width", 64, "max output width of the memory map")
+	fs.Parse(args)
+
+	if cfg.Mode == "paging" || cfg.Mode == "managed" {
+		fmt.Printf("The allocator server does not support %s mode.\n", cfg.Mode)
+		return
+	}
+	heap, err := memsim.NewHeap(cfg)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	server := NewAllocServer(heap)
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Println("Error starting server:", err)
+		return
+	}
+	defer listener.Close()
+
+	fmt.Printf("Allocator server is running on %s (%d cells, %s mode)\n", *addr, heap.Size(), heap.Mode())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			fmt.Println("Error accepting connection:", err)
+			continue
+		}
+		go handleAllocConnection(conn, server)
+	}
+}
@@ -2,142 +2,577 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
-)
 
-var memory []int
-var maxOutputWidth int
+	"github.com/YaremaTymchyshyn/go-coding-4/task1/memsim"
+)
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			runBench(os.Args[2:])
+			return
+		case "serve":
+			runServer(os.Args[2:])
+			return
+		}
+	}
+
+	var cfg memsim.Config
+	flag.StringVar(&cfg.Strategy, "strategy", "first", "placement strategy: "+strings.Join(memsim.Strategies, ", "))
+	flag.StringVar(&cfg.Mode, "mode", "contiguous", "allocation mode: "+strings.Join(memsim.Modes, ", "))
+	flag.IntVar(&cfg.PageSize, "page", 4, "page and frame size in cells for paging mode")
+	flag.IntVar(&cfg.Size, "mem", 0, "memory size in cells")
+	flag.IntVar(&cfg.Width, "width", 0, "max output width of the memory map")
+	trace := flag.String("trace", "", "run commands from a trace file instead of the prompt (requires -mem and -width)")
+	flag.Parse()
+
 	reader := bufio.NewReader(os.Stdin)
 
-	fmt.Println("Please set memory size and max output width:")
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
-	params := strings.Split(input, " ")
+	if *trace == "" && (cfg.Size == 0 || cfg.Width == 0) {
+		fmt.Println("Please set memory size and max output width:")
+		input, _ := reader.ReadString('\n')
+		input = strings.TrimSpace(input)
+		params := strings.Split(input, " ")
+
+		cfg.Size, _ = strconv.Atoi(params[0])
+		if len(params) > 1 {
+			cfg.Width, _ = strconv.Atoi(params[1])
+		}
+	}
 
-	memSize, _ := strconv.Atoi(params[0])
-	maxOutputWidth, _ = strconv.Atoi(params[1])
+	if err := createArena("main", cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	useArena("main")
+	defaultConfig = cfg
 
-	memory = make([]int, memSize)
-	for i := range memory {
-		memory[i] = -1
+	if *trace != "" {
+		if err := runTrace(*trace); err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading trace:", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	fmt.Println("Type 'help' for additional info.")
 
 	for {
 		fmt.Print("> ")
-		input, _ := reader.ReadString('\n')
+		input, err := reader.ReadString('\n')
+		if err != nil && input == "" {
+			return
+		}
 		input = strings.TrimSpace(input)
 
-		parts := strings.Split(input, " ")
-		command := parts[0]
+		if !runCommand(strings.Split(input, " ")) {
+			return
+		}
+	}
+}
+
+// runTrace replays a file of REPL commands without prompting. Each command is
+// echoed before its output so that runs can be diffed against each other.
+// Blank lines and lines starting with '#' are skipped.
+func runTrace(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fmt.Println(">", line)
+		if !runCommand(strings.Fields(line)) {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+func runCommand(parts []string) bool {
+	command := parts[0]
 
+	mode := cur.Mode()
+	defer printEvents(cur)
+	if cur.SnapshotsSupported() {
 		switch command {
-		case "help":
-			printHelp()
-		case "exit":
-			return
-		case "print":
-			printMemory()
-		case "allocate":
-			if len(parts) < 2 {
-				fmt.Println("Please provide the number of cells to allocate.")
+		case "allocate", "free", "realloc", "compact", "strategy", "load", "ref", "unref", "root", "gc",
+			"quota", "watermark", "oomkill":
+			cur.Begin()
+			defer cur.Commit()
+		}
+	} else {
+		switch command {
+		case "save", "load", "undo", "redo":
+			fmt.Printf("Snapshots are not supported in %s mode.\n", mode)
+			return true
+		}
+	}
+
+	if mode == "paging" {
+		switch command {
+		case "allocate", "free", "realloc", "compact", "where", "strategy", "quota", "watermark", "oomkill":
+			fmt.Println("Not available in paging mode; use 'proc' and 'access'.")
+			return true
+		}
+	} else {
+		switch command {
+		case "proc", "access", "pagetable", "procs", "policy", "pagestats":
+			fmt.Println("Paging commands need -mode paging.")
+			return true
+		}
+	}
+
+	if mode == "managed" {
+		if command == "free" {
+			fmt.Println("Blocks in a managed heap are reclaimed by 'gc'.")
+			return true
+		}
+	} else {
+		switch command {
+		case "ref", "unref", "root", "refs", "gc":
+			fmt.Println("Garbage collection commands need -mode managed.")
+			return true
+		}
+	}
+
+	switch command {
+	case "help":
+		printHelp()
+	case "exit":
+		printLeakReports()
+		return false
+	case "print":
+		format := "text"
+		if len(parts) > 1 {
+			format = parts[1]
+		}
+		var out bytes.Buffer
+		if err := cur.Render(&out, format); err != nil {
+			fmt.Println(err)
+			return true
+		}
+		if len(parts) < 3 {
+			fmt.Print(out.String())
+			return true
+		}
+		if err := os.WriteFile(parts[2], out.Bytes(), 0644); err != nil {
+			fmt.Println("Error writing memory map:", err)
+			return true
+		}
+		fmt.Println("Memory map written to", parts[2])
+	case "stats":
+		printStats(cur)
+	case "slabs":
+		if mode != "slab" {
+			fmt.Println("Slab report needs -mode slab.")
+			return true
+		}
+		printSlabs(cur)
+	case "allocate":
+		if len(parts) < 2 {
+			fmt.Println("Please provide the number of cells to allocate.")
+			return true
+		}
+		numCells, err := strconv.Atoi(parts[1])
+		if err != nil || numCells <= 0 {
+			fmt.Println("Invalid number of cells.")
+			return true
+		}
+		owner, align := "", 1
+		for i := 2; i < len(parts); i++ {
+			if parts[i] != "align" {
+				owner = parts[i]
 				continue
 			}
-			numCells, err := strconv.Atoi(parts[1])
-			if err != nil || numCells <= 0 {
-				fmt.Println("Invalid number of cells.")
-				continue
+			if i+1 < len(parts) {
+				align, err = strconv.Atoi(parts[i+1])
 			}
-			allocateMemory(numCells)
-		case "free":
-			if len(parts) < 2 {
-				fmt.Println("Please provide the block ID to free.")
-				continue
+			if i+1 >= len(parts) || err != nil || align <= 0 {
+				fmt.Println("Invalid alignment.")
+				return true
 			}
-			blockID, err := strconv.Atoi(parts[1])
-			if err != nil || blockID < 0 {
-				fmt.Println("Invalid block ID.")
-				continue
+			i++
+		}
+		id, err := cur.Allocate(numCells, owner, align)
+		switch {
+		case errors.Is(err, memsim.ErrAlignment):
+			fmt.Println("Buddy mode only supports power-of-two alignment.")
+		case errors.Is(err, memsim.ErrQuotaExceeded):
+			printQuotaExceeded(err)
+		case errors.Is(err, memsim.ErrOutOfMemory):
+			fmt.Println("Not enough memory to allocate.")
+			if free := cur.FreeCells(); free >= numCells && mode != "buddy" && mode != "slab" {
+				fmt.Printf("%d cells are free in total; 'compact' may help.\n", free)
 			}
-			freeMemory(blockID)
+		case err != nil:
+			fmt.Println(err)
 		default:
-			fmt.Println("Unknown command. Type 'help' for a list of commands.")
+			fmt.Println(id)
+		}
+	case "free":
+		if len(parts) < 2 {
+			fmt.Println("Please provide the block ID to free.")
+			return true
+		}
+		blockID, err := strconv.Atoi(parts[1])
+		if err != nil || blockID < 0 {
+			fmt.Println("Invalid block ID.")
+			return true
+		}
+		err = cur.Free(blockID)
+		switch {
+		case errors.Is(err, memsim.ErrDoubleFree):
+			fmt.Printf("Double free: block %d has already been freed.\n", blockID)
+		case errors.Is(err, memsim.ErrInvalidBlock):
+			if inside, ok := cur.BlockAt(blockID); ok {
+				fmt.Printf("Invalid free: cell %d is inside block %d, not at its start.\n", blockID, inside)
+			} else {
+				fmt.Printf("Invalid free: %d is not the start of a live block.\n", blockID)
+			}
+		case err != nil:
+			fmt.Println(err)
+		}
+	case "realloc":
+		if len(parts) < 3 {
+			fmt.Println("Please provide the block ID and the new number of cells.")
+			return true
 		}
+		blockID, err := strconv.Atoi(parts[1])
+		if err != nil || blockID < 0 {
+			fmt.Println("Invalid block ID.")
+			return true
+		}
+		newSize, err := strconv.Atoi(parts[2])
+		if err != nil || newSize <= 0 {
+			fmt.Println("Invalid number of cells.")
+			return true
+		}
+		id, err := cur.Realloc(blockID, newSize)
+		switch {
+		case errors.Is(err, memsim.ErrInvalidBlock):
+			fmt.Println("Block not found.")
+		case errors.Is(err, memsim.ErrQuotaExceeded):
+			printQuotaExceeded(err)
+		case errors.Is(err, memsim.ErrOutOfMemory):
+			fmt.Println("Not enough memory to reallocate.")
+		case err != nil:
+			fmt.Println(err)
+		default:
+			fmt.Println(id)
+		}
+	case "compact":
+		moved, err := cur.Compact()
+		if err != nil {
+			fmt.Printf("Compaction is not supported in %s mode.\n", mode)
+			return true
+		}
+		if len(moved) == 0 {
+			fmt.Println("Memory is already compact.")
+			return true
+		}
+		printRelocations(moved)
+	case "where":
+		if len(parts) < 2 {
+			fmt.Println("Please provide the block ID to look up.")
+			return true
+		}
+		blockID, err := strconv.Atoi(parts[1])
+		if err != nil || blockID < 0 {
+			fmt.Println("Invalid block ID.")
+			return true
+		}
+		id, ok := cur.Resolve(blockID)
+		if !ok {
+			fmt.Printf("Block %d is not allocated.\n", blockID)
+		} else if id != blockID {
+			fmt.Printf("Block %d was moved to %d.\n", blockID, id)
+		} else {
+			fmt.Printf("Block %d has not moved.\n", blockID)
+		}
+	case "strategy":
+		if mode == "buddy" {
+			fmt.Println("Placement strategies do not apply in buddy mode.")
+			return true
+		}
+		if len(parts) < 2 {
+			fmt.Printf("Current strategy: %s (available: %s)\n", cur.Strategy(), strings.Join(memsim.Strategies, ", "))
+			return true
+		}
+		if err := cur.SetStrategy(parts[1]); err != nil {
+			fmt.Println(err)
+			return true
+		}
+		fmt.Println("Strategy set to", cur.Strategy())
+	case "save", "load":
+		if len(parts) < 2 {
+			fmt.Println("Please provide the snapshot file name.")
+			return true
+		}
+		var err error
+		if command == "save" {
+			err = cur.Save(parts[1])
+		} else {
+			err = cur.Load(parts[1])
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return true
+		}
+		fmt.Println("OK")
+	case "proc":
+		if len(parts) < 3 {
+			fmt.Println("Please provide the process ID and the number of pages.")
+			return true
+		}
+		pid, err := strconv.Atoi(parts[1])
+		if err != nil || pid < 0 {
+			fmt.Println("Invalid process ID.")
+			return true
+		}
+		numPages, err := strconv.Atoi(parts[2])
+		if err != nil || numPages <= 0 {
+			fmt.Println("Invalid number of pages.")
+			return true
+		}
+		if err := cur.CreateProcess(pid, numPages); err != nil {
+			fmt.Printf("Process %d already exists.\n", pid)
+			return true
+		}
+		fmt.Printf("Process %d created with %d pages (%d cells).\n", pid, numPages, numPages*cur.PageSize())
+	case "access", "pagetable":
+		if len(parts) < 2 || command == "access" && len(parts) < 3 {
+			fmt.Println("Please provide the process ID and the virtual address.")
+			return true
+		}
+		pid, err := strconv.Atoi(parts[1])
+		if err != nil || pid < 0 {
+			fmt.Println("Invalid process ID.")
+			return true
+		}
+		if command == "pagetable" {
+			printPageTable(cur, pid)
+			return true
+		}
+		addr, err := strconv.Atoi(parts[2])
+		if err != nil || addr < 0 {
+			fmt.Println("Invalid address.")
+			return true
+		}
+		a, err := cur.Access(pid, addr)
+		switch {
+		case errors.Is(err, memsim.ErrUnknownProcess):
+			fmt.Printf("Unknown process %d.\n", pid)
+		case errors.Is(err, memsim.ErrSegfault):
+			fmt.Printf("Segmentation fault: address %d is outside process %d.\n", addr, pid)
+		case err != nil:
+			fmt.Println(err)
+		case !a.Fault:
+			fmt.Printf("Hit: page %d -> frame %d, physical address %d\n", a.Page, a.Frame, a.Physical)
+		default:
+			evicted := ""
+			if a.Evicted {
+				evicted = fmt.Sprintf(", evicted process %d page %d", a.EvictedPID, a.EvictedPage)
+			}
+			fmt.Printf("Page fault: page %d loaded into frame %d%s, physical address %d\n", a.Page, a.Frame, evicted, a.Physical)
+		}
+	case "procs":
+		printProcesses(cur)
+	case "policy":
+		if len(parts) < 2 {
+			fmt.Printf("Current policy: %s (available: %s)\n", cur.Policy(), strings.Join(memsim.Policies, ", "))
+			return true
+		}
+		err := cur.SetPolicy(parts[1])
+		switch {
+		case errors.Is(err, memsim.ErrOfflinePolicy):
+			fmt.Println("Optimal replacement needs future references; see 'pagestats'.")
+		case err != nil:
+			fmt.Println(err)
+		default:
+			fmt.Println("Replacement policy set to", cur.Policy())
+		}
+	case "pagestats":
+		printPageStats(cur)
+	case "ref", "unref":
+		if len(parts) < 3 {
+			fmt.Println("Please provide the referencing and the referenced block IDs.")
+			return true
+		}
+		from, err1 := strconv.Atoi(parts[1])
+		to, err2 := strconv.Atoi(parts[2])
+		if err1 != nil || err2 != nil {
+			fmt.Println("Invalid block ID.")
+			return true
+		}
+		var err error
+		if command == "ref" {
+			err = cur.AddRef(from, to)
+		} else {
+			err = cur.RemoveRef(from, to)
+		}
+		if errors.Is(err, memsim.ErrNoReference) {
+			fmt.Printf("Block %d does not reference %d.\n", from, to)
+			return true
+		}
+		printGCError(err)
+	case "root":
+		if len(parts) < 3 || parts[1] != "add" && parts[1] != "remove" {
+			fmt.Println("Usage: root add|remove <id>")
+			return true
+		}
+		id, err := strconv.Atoi(parts[2])
+		if err != nil {
+			fmt.Println("Invalid block ID.")
+			return true
+		}
+		if parts[1] == "add" {
+			err = cur.AddRoot(id)
+		} else {
+			err = cur.RemoveRoot(id)
+		}
+		printGCError(err)
+	case "refs":
+		printRefs(cur)
+	case "gc":
+		c, _ := cur.CollectGarbage(len(parts) > 1 && parts[1] == "compact")
+		fmt.Printf("Collected %d blocks (%d cells): [%s]\n", len(c.Freed), c.Cells, joinInts(c.Freed))
+		printRelocations(c.Moved)
+	case "quota":
+		if len(parts) < 2 {
+			printQuotas(cur)
+			return true
+		}
+		if len(parts) < 3 {
+			fmt.Println("Please provide the owner and the quota in cells.")
+			return true
+		}
+		cells, err := strconv.Atoi(parts[2])
+		if err != nil || cells < 0 {
+			fmt.Println("Invalid number of cells.")
+			return true
+		}
+		cur.SetQuota(parts[1], cells)
+		if cells == 0 {
+			fmt.Printf("Quota for %s removed.\n", parts[1])
+		} else {
+			fmt.Printf("Quota for %s set to %d cells.\n", parts[1], cells)
+		}
+	case "watermark":
+		if len(parts) < 3 {
+			low, high := cur.Watermarks()
+			fmt.Printf("Watermarks: low %s, high %s\n", percentOrOff(low), percentOrOff(high))
+			return true
+		}
+		low, err1 := strconv.Atoi(parts[1])
+		high, err2 := strconv.Atoi(parts[2])
+		if err1 != nil || err2 != nil {
+			fmt.Println("Invalid watermark.")
+			return true
+		}
+		if err := cur.SetWatermarks(low, high); err != nil {
+			fmt.Println("Invalid watermarks:", err)
+			return true
+		}
+		fmt.Printf("Watermarks set to low %s, high %s.\n", percentOrOff(low), percentOrOff(high))
+	case "oomkill":
+		if len(parts) > 1 && parts[1] != "on" && parts[1] != "off" {
+			fmt.Println("Usage: oomkill [on|off]")
+			return true
+		}
+		if len(parts) > 1 {
+			cur.SetOOMKiller(parts[1] == "on")
+		}
+		if cur.OOMKiller() {
+			fmt.Println("OOM killer is on.")
+		} else {
+			fmt.Println("OOM killer is off.")
+		}
+	case "arena":
+		runArenaCommand(parts[1:])
+	case "undo":
+		if !cur.Undo() {
+			fmt.Println("Nothing to undo.")
+		}
+	case "redo":
+		if !cur.Redo() {
+			fmt.Println("Nothing to redo.")
+		}
+	default:
+		fmt.Println("Unknown command. Type 'help' for a list of commands.")
 	}
+	return true
 }
 
 func printHelp() {
 	fmt.Println(`Available commands:
 
  help  - show this help
- exit  - exit this program
- print - print memory blocks map
- allocate <num> - allocate <num> cells. Returns block first cell number
- free <num> - free block with first cell number <num>`)
-}
+ exit  - print a report of leaked blocks by owner and exit this program
+ print [text|ansi|svg] [file] - print memory blocks map, or write it to <file>.
+   text pads cells to the widest block ID, ansi gives every block its own colour
+   and svg draws the map as an image
+ stats - print usage and fragmentation statistics
+ allocate <num> [owner] [align <k>] - allocate <num> cells tagged with an optional owner,
+   starting at a multiple of <k>. Returns block first cell number
+ free <num> - free block with first cell number <num>. Reports double and invalid frees
+ realloc <id> <num> - resize block <id> to <num> cells, moving it if needed. Returns block first cell number
+ strategy [name] - show or switch placement strategy (first, best, worst, next)
+ compact - move all blocks towards cell 0 and print old -> new block IDs
+ where <num> - show the current ID of a block that was moved by compact
+ save <file> - save memory, blocks and settings to a JSON file
+ load <file> - restore a state saved with 'save'
+ undo - revert the last command that changed memory or settings
+ redo - reapply the last undone command
 
-func printMemory() {
-	for i := 0; i < len(memory); i += maxOutputWidth {
-		end := i + maxOutputWidth
-		if end > len(memory) {
-			end = len(memory)
-		}
-		fmt.Print("|")
-		currentBlock := -1
-		for j := i; j < end; j++ {
-			if memory[j] == -1 {
-				fmt.Print(" ")
-			} else {
-				if memory[j] != currentBlock {
-					currentBlock = memory[j]
-					fmt.Printf("%d", memory[j])
-				} else {
-					fmt.Print("x")
-				}
-			}
-		}
-		fmt.Println("|")
-	}
-}
+Paging mode (-mode paging, -page <cells>):
+ proc <pid> <pages> - create a process with <pages> virtual pages
+ access <pid> <addr> - access virtual address <addr>, loading its page on a fault
+ pagetable <pid> - print the page table of a process
+ procs - list processes
+ policy [name] - show or switch page replacement policy (fifo, lru, clock)
+ pagestats - compare page faults of fifo, lru, clock and optimal on the accesses so far
 
-func allocateMemory(numCells int) {
-	start := -1
-	for i := 0; i <= len(memory)-numCells; i++ {
-		isFree := true
-		for j := 0; j < numCells; j++ {
-			if memory[i+j] != -1 {
-				isFree = false
-				break
-			}
-		}
-		if isFree {
-			start = i
-			break
-		}
-	}
+Start with -mode buddy to round memory to a power of two and serve
+allocations from buddy blocks. print then lists each block's order.
+Start with -mode slab to serve requests of up to 16 cells from slabs of
+size classes 1, 2, 4, 8 and 16; larger ones use the placement strategy.
+ slabs - print per-class slab occupancy
 
-	if start == -1 {
-		fmt.Println("Not enough memory to allocate.")
-		return
-	}
+Managed heap mode (-mode managed); blocks are reclaimed by the collector, not by free:
+ ref <from> <to> - record that block <from> references block <to>
+ unref <from> <to> - remove that reference
+ root add|remove <id> - add or remove a block from the root set
+ refs - print roots and references
+ gc [compact] - free every block unreachable from the roots, then optionally compact
 
-	for i := start; i < start+numCells; i++ {
-		memory[i] = start
-	}
+Memory pressure:
+ quota [<owner> <cells>] - list quotas, or limit the cells the blocks of <owner> may use (0 removes)
+ watermark [<low> <high>] - show or set the usage percentages that raise pressure warnings (0 is off)
+ oomkill [on|off] - show or toggle freeing the largest other owner's blocks when an allocation fails
 
-	fmt.Println(start)
-}
+Arenas (independent heaps; the first one is called 'main'):
+ arena create <name> <size> [strategy] - create an arena in the startup mode
+ arena use <name> - make <name> the arena all other commands act on
+ arena list - list arenas
 
-func freeMemory(blockID int) {
-	for i := 0; i < len(memory); i++ {
-		if memory[i] == blockID {
-			memory[i] = -1
-		}
-	}
+Start with -mem <size> -width <width> -trace <file> to replay a file of
+commands without prompts. Run 'task1 bench [-dist <name>] [-seed <n>] ...' to
+compare the placement strategies on random workloads; see 'task1 bench -h'.
+Run 'task1 serve [-addr <addr>] ...' to share one heap between TCP clients that
+send JSON requests such as {"action": "allocate", "size": 4}.`)
 }
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// stronglyConnected returns the strongly connected components of the set
+// graph with Tarjan's algorithm. Names inside a component are sorted and
+// components are ordered by their first name.
+func stronglyConnected(weightedGraph map[string]map[string]int) [][]string {
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var visit func(v string)
+	visit = func(v string) {
+		index[v] = len(index)
+		low[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, u := range sortedNames(weightedGraph[v]) {
+			if _, seen := index[u]; !seen {
+				visit(u)
+				low[v] = min(low[v], low[u])
+			} else if onStack[u] {
+				low[v] = min(low[v], index[u])
+			}
+		}
+
+		if low[v] == index[v] {
+			var component []string
+			for {
+				u := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[u] = false
+				component = append(component, u)
+				if u == v {
+					break
+				}
+			}
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+
+	for _, v := range sortedNames(weightedGraph) {
+		if _, seen := index[v]; !seen {
+			visit(v)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	return components
+}
+
+type cycle struct {
+	sets   []string
+	weight int
+}
+
+// findCycles lists the elementary cycles of the set graph, each starting at
+// its alphabetically smallest set, and stops after limit cycles since their
+// number can grow exponentially. Only sets of the same strongly connected
+// component can share a cycle, so the search never leaves one.
+func findCycles(weightedGraph map[string]map[string]int, limit int) (cycles []cycle, complete bool) {
+	component := make(map[string]int)
+	for i, c := range stronglyConnected(weightedGraph) {
+		for _, name := range c {
+			component[name] = i
+		}
+	}
+
+	var path []string
+	onPath := make(map[string]bool)
+	var search func(start, v string, weight int) bool
+	search = func(start, v string, weight int) bool {
+		path = append(path, v)
+		onPath[v] = true
+		defer func() {
+			path = path[:len(path)-1]
+			onPath[v] = false
+		}()
+
+		for _, u := range sortedNames(weightedGraph[v]) {
+			if component[u] != component[start] || u < start {
+				continue
+			}
+			w := weight + weightedGraph[v][u]
+			if u == start {
+				if len(cycles) == limit {
+					return false
+				}
+				cycles = append(cycles, cycle{append([]string(nil), path...), w})
+			} else if !onPath[u] && !search(start, u, w) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, start := range sortedNames(weightedGraph) {
+		if !search(start, start, 0) {
+			return cycles, false
+		}
+	}
+	return cycles, true
+}
+
+// layers assigns every set to a layer one above the highest layer it depends
+// on, so layer 0 holds the sets that depend on no other set. It fails when
+// the graph has a cycle.
+func layers(weightedGraph map[string]map[string]int) ([][]string, bool) {
+	layer := make(map[string]int)
+	state := make(map[string]int) // 1 while visiting, 2 when done
+	var visit func(v string) bool
+	visit = func(v string) bool {
+		switch state[v] {
+		case 1:
+			return false
+		case 2:
+			return true
+		}
+		state[v] = 1
+		for u := range weightedGraph[v] {
+			if !visit(u) {
+				return false
+			}
+			layer[v] = max(layer[v], layer[u]+1)
+		}
+		state[v] = 2
+		return true
+	}
+
+	var result [][]string
+	for _, v := range sortedNames(weightedGraph) {
+		if !visit(v) {
+			return nil, false
+		}
+	}
+	for _, v := range sortedNames(weightedGraph) {
+		for len(result) <= layer[v] {
+			result = append(result, nil)
+		}
+		result[layer[v]] = append(result[layer[v]], v)
+	}
+	return result, true
+}
+
+func writeAnalysis(w io.Writer, weightedGraph map[string]map[string]int, maxCycles int) {
+	fmt.Fprintln(w, "\nСильно зв'язні компоненти:")
+	for _, c := range stronglyConnected(weightedGraph) {
+		fmt.Fprintf(w, "  {%s}\n", strings.Join(c, ", "))
+	}
+
+	cycles, complete := findCycles(weightedGraph, maxCycles)
+	if len(cycles) == 0 {
+		fmt.Fprintln(w, "\nЦиклів залежностей немає.")
+	} else {
+		fmt.Fprintln(w, "\nЦикли залежностей:")
+		for _, c := range cycles {
+			fmt.Fprintf(w, "  %s -> %s [вага: %d]\n", strings.Join(c.sets, " -> "), c.sets[0], c.weight)
+		}
+		if !complete {
+			fmt.Fprintf(w, "  ... показано перші %d циклів\n", maxCycles)
+		}
+	}
+
+	if result, ok := layers(weightedGraph); ok {
+		fmt.Fprintln(w, "\nШари (0 - множини без залежностей):")
+		for i, layer := range result {
+			fmt.Fprintf(w, "  %d: %s\n", i, strings.Join(layer, ", "))
+		}
+	} else {
+		fmt.Fprintln(w, "\nГраф містить цикли, тому розбиття на шари неможливе.")
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var discoverMethods = []string{"scc", "labels", "louvain"}
+
+// undirected is the graph that community detection works on: every edge
+// v -> u between different vertices adds 1 to the weight between v and u in
+// both directions. loop holds weight that is internal to a node, which only
+// appears once Louvain merges communities into single nodes.
+type undirected struct {
+	adj  []map[int]float64
+	loop []float64
+}
+
+func (g undirected) degree(i int) float64 {
+	k := g.loop[i]
+	for _, w := range g.adj[i] {
+		k += w
+	}
+	return k
+}
+
+func (g undirected) totalDegree() float64 {
+	total := 0.0
+	for i := range g.adj {
+		total += g.degree(i)
+	}
+	return total
+}
+
+// graphVertices returns every vertex of g that appears in an edge, sorted.
+func graphVertices(g Graph) []int {
+	seen := make(map[int]bool)
+	for v, neighbours := range g.adjacencyList {
+		seen[v] = true
+		for _, u := range neighbours {
+			seen[u] = true
+		}
+	}
+	vertices := make([]int, 0, len(seen))
+	for v := range seen {
+		vertices = append(vertices, v)
+	}
+	sort.Ints(vertices)
+	return vertices
+}
+
+func toUndirected(g Graph, vertices []int) undirected {
+	pos := make(map[int]int, len(vertices))
+	for i, v := range vertices {
+		pos[v] = i
+	}
+	u := undirected{adj: make([]map[int]float64, len(vertices)), loop: make([]float64, len(vertices))}
+	for i := range u.adj {
+		u.adj[i] = make(map[int]float64)
+	}
+	for v, neighbours := range g.adjacencyList {
+		for _, n := range neighbours {
+			if v != n {
+				u.adj[pos[v]][pos[n]]++
+				u.adj[pos[n]][pos[v]]++
+			}
+		}
+	}
+	return u
+}
+
+// modularity of a partition of g, where community[i] is the community of
+// node i. Edges are taken as undirected and self-loops of the input graph
+// are ignored.
+func modularity(g undirected, community []int) float64 {
+	m2 := g.totalDegree()
+	if m2 == 0 {
+		return 0
+	}
+	internal := make(map[int]float64)
+	total := make(map[int]float64)
+	for i := range g.adj {
+		c := community[i]
+		internal[c] += g.loop[i]
+		total[c] += g.degree(i)
+		for j, w := range g.adj[i] {
+			if community[j] == c {
+				internal[c] += w
+			}
+		}
+	}
+	q := 0.0
+	for c, tot := range total {
+		q += internal[c]/m2 - (tot/m2)*(tot/m2)
+	}
+	return q
+}
+
+// sccCommunities puts every strongly connected component of the vertex graph
+// into its own community.
+func sccCommunities(g Graph, vertices []int) []int {
+	pos := make(map[int]int, len(vertices))
+	for i, v := range vertices {
+		pos[v] = i
+	}
+	index := make([]int, len(vertices))
+	low := make([]int, len(vertices))
+	community := make([]int, len(vertices))
+	onStack := make([]bool, len(vertices))
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	next, components := 0, 0
+
+	var visit func(i int)
+	visit = func(i int) {
+		index[i], low[i] = next, next
+		next++
+		stack = append(stack, i)
+		onStack[i] = true
+		for _, u := range g.adjacencyList[vertices[i]] {
+			j := pos[u]
+			if index[j] == -1 {
+				visit(j)
+				low[i] = min(low[i], low[j])
+			} else if onStack[j] {
+				low[i] = min(low[i], index[j])
+			}
+		}
+		if low[i] == index[i] {
+			for {
+				j := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[j] = false
+				community[j] = components
+				if j == i {
+					break
+				}
+			}
+			components++
+		}
+	}
+	for i := range vertices {
+		if index[i] == -1 {
+			visit(i)
+		}
+	}
+	return community
+}
+
+// labelCommunities runs label propagation: every node repeatedly takes the
+// label carried by the largest weight of its neighbours until no label
+// changes. Nodes are visited in a shuffled order and ties are broken at
+// random, keeping the current label when it is among the best, as otherwise
+// one label tends to flood the whole graph. The seed is fixed so the same
+// graph always gives the same sets.
+func labelCommunities(g undirected) []int {
+	random := rand.New(rand.NewSource(1))
+	label := make([]int, len(g.adj))
+	order := make([]int, len(g.adj))
+	for i := range label {
+		label[i], order[i] = i, i
+	}
+	for iteration := 0; iteration < 100; iteration++ {
+		changed := false
+		random.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		for _, i := range order {
+			if len(g.adj[i]) == 0 {
+				continue
+			}
+			weights := make(map[int]float64)
+			for j, w := range g.adj[i] {
+				weights[label[j]] += w
+			}
+			var best []int
+			for l, w := range weights {
+				if len(best) == 0 || w > weights[best[0]] {
+					best = []int{l}
+				} else if w == weights[best[0]] {
+					best = append(best, l)
+				}
+			}
+			if slices.Contains(best, label[i]) {
+				continue
+			}
+			slices.Sort(best)
+			label[i] = best[random.Intn(len(best))]
+			changed = true
+		}
+		if !changed {
+			break
+		}
+	}
+	return label
+}
+
+// louvainCommunities greedily moves nodes to the neighbouring community with
+// the largest modularity gain, then merges every community into one node and
+// repeats on the smaller graph until no node moves.
+func louvainCommunities(g undirected) []int {
+	m2 := g.totalDegree()
+	community := make([]int, len(g.adj)) // of every original vertex
+	for i := range community {
+		community[i] = i
+	}
+	if m2 == 0 {
+		return community
+	}
+
+	for {
+		node := make([]int, len(g.adj)) // community of every node of g
+		total := make([]float64, len(g.adj))
+		degree := make([]float64, len(g.adj))
+		for i := range g.adj {
+			node[i] = i
+			degree[i] = g.degree(i)
+			total[i] = degree[i]
+		}
+
+		moved := false
+		for improved := true; improved; {
+			improved = false
+			for i := range g.adj {
+				links := make(map[int]float64)
+				for j, w := range g.adj[i] {
+					links[node[j]] += w
+				}
+				current := node[i]
+				total[current] -= degree[i]
+
+				best, bestGain := current, links[current]-total[current]*degree[i]/m2
+				candidates := make([]int, 0, len(links))
+				for c := range links {
+					candidates = append(candidates, c)
+				}
+				sort.Ints(candidates)
+				for _, c := range candidates {
+					if gain := links[c] - total[c]*degree[i]/m2; gain > bestGain+1e-12 {
+						best, bestGain = c, gain
+					}
+				}
+
+				total[best] += degree[i]
+				if best != current {
+					node[i] = best
+					improved, moved = true, true
+				}
+			}
+		}
+		if !moved {
+			return community
+		}
+
+		renumber := make(map[int]int)
+		for i := range node {
+			if _, ok := renumber[node[i]]; !ok {
+				renumber[node[i]] = len(renumber)
+			}
+			node[i] = renumber[node[i]]
+		}
+		for v := range community {
+			community[v] = node[community[v]]
+		}
+
+		merged := undirected{adj: make([]map[int]float64, len(renumber)), loop: make([]float64, len(renumber))}
+		for c := range merged.adj {
+			merged.adj[c] = make(map[int]float64)
+		}
+		for i := range g.adj {
+			merged.loop[node[i]] += g.loop[i]
+			for j, w := range g.adj[i] {
+				if node[i] == node[j] {
+					merged.loop[node[i]] += w
+				} else {
+					merged.adj[node[i]][node[j]] += w
+				}
+			}
+		}
+		g = merged
+	}
+}
+
+// discoverSets proposes sets for the vertices of g. Sets are named m1, m2, ...
+// in the order of their smallest vertex.
+func discoverSets(g Graph, method string) (map[string][]int, float64, error) {
+	vertices := graphVertices(g)
+	u := toUndirected(g, vertices)
+
+	var community []int
+	switch method {
+	case "scc":
+		community = sccCommunities(g, vertices)
+	case "labels":
+		community = labelCommunities(u)
+	case "louvain":
+		community = louvainCommunities(u)
+	default:
+		return nil, 0, fmt.Errorf("невідомий метод %q (доступні: %s)", method, strings.Join(discoverMethods, ", "))
+	}
+
+	names := make(map[int]string)
+	sets := make(map[string][]int)
+	for i, v := range vertices {
+		name, ok := names[community[i]]
+		if !ok {
+			name = fmt.Sprintf("m%d", len(names)+1)
+			names[community[i]] = name
+		}
+		sets[name] = append(sets[name], v)
+	}
+	return sets, modularity(u, community), nil
+}
+
+func writeSets(w io.Writer, sets map[string][]int) {
+	names := sortedNames(sets)
+	sort.Slice(names, func(i, j int) bool { return sets[names[i]][0] < sets[names[j]][0] })
+	for _, name := range names {
+		vertices := make([]string, len(sets[name]))
+		for i, v := range sets[name] {
+			vertices[i] = fmt.Sprint(v)
+		}
+		fmt.Fprintf(w, "%s %s\n", name, strings.Join(vertices, " "))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var exportFormats = []string{"text", "dot", "json", "csv"}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeWeightedGraph(w io.Writer, format string, weightedGraph map[string]map[string]int, sets map[string][]int) error {
+	switch format {
+	case "text":
+		return writeText(w, weightedGraph)
+	case "dot":
+		return writeDOT(w, weightedGraph, sets)
+	case "json":
+		return writeJSON(w, weightedGraph)
+	case "csv":
+		return writeCSV(w, weightedGraph)
+	}
+	return fmt.Errorf("невідомий формат %q (доступні: %s)", format, strings.Join(exportFormats, ", "))
+}
+
+func writeText(w io.Writer, weightedGraph map[string]map[string]int) error {
+	fmt.Fprintln(w, "\nЗважений граф:")
+	for _, nameM := range sortedNames(weightedGraph) {
+		for _, nameN := range sortedNames(weightedGraph[nameM]) {
+			if _, err := fmt.Fprintf(w, "%s -> %s [вага: %d]\n", nameM, nameN, weightedGraph[nameM][nameN]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeDOT labels every edge with its dependency index and sizes every node
+// by the number of vertices in its set, the largest set being twice as wide
+// as a set of one vertex.
+func writeDOT(w io.Writer, weightedGraph map[string]map[string]int, sets map[string][]int) error {
+	largest := 1
+	for _, set := range sets {
+		largest = max(largest, len(set))
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph sets {\n")
+	b.WriteString("  node [shape=circle, fixedsize=true];\n")
+	for _, name := range sortedNames(weightedGraph) {
+		size := len(sets[name])
+		width := 0.75 * (1 + math.Sqrt(float64(size)/float64(largest)))
+		fmt.Fprintf(&b, "  %s [label=%s, width=%.2f];\n", strconv.Quote(name), strconv.Quote(fmt.Sprintf("%s\n%d", name, size)), width)
+	}
+	for _, nameM := range sortedNames(weightedGraph) {
+		for _, nameN := range sortedNames(weightedGraph[nameM]) {
+			fmt.Fprintf(&b, "  %s -> %s [label=\"%d\"];\n", strconv.Quote(nameM), strconv.Quote(nameN), weightedGraph[nameM][nameN])
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeJSON writes the adjacency of the set graph as an object of objects.
+// encoding/json sorts map keys, which keeps the output stable.
+func writeJSON(w io.Writer, weightedGraph map[string]map[string]int) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(weightedGraph)
+}
+
+// writeCSV writes the adjacency matrix with the set names as the header row
+// and first column. Row M, column N holds the dependency index of M on N.
+func writeCSV(w io.Writer, weightedGraph map[string]map[string]int) error {
+	names := sortedNames(weightedGraph)
+	writer := csv.NewWriter(w)
+	writer.Write(append([]string{""}, names...))
+	for _, nameM := range names {
+		row := []string{nameM}
+		for _, nameN := range names {
+			row = append(row, strconv.Itoa(weightedGraph[nameM][nameN]))
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	return writer.Error()
+}
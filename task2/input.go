@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var graphFormats = []string{"edges", "csv", "json", "dot"}
+
+// graphFormat picks the format of a graph file from its extension when no
+// format was given explicitly.
+func graphFormat(path, format string) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".json":
+		return "json"
+	case ".dot", ".gv":
+		return "dot"
+	}
+	return "edges"
+}
+
+func readGraphFile(path, format string) (Graph, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Graph{}, err
+	}
+	defer file.Close()
+
+	g := Graph{adjacencyList: make(map[int][]int)}
+	switch graphFormat(path, format) {
+	case "edges":
+		err = readEdgeList(file, &g)
+	case "csv":
+		err = readCSV(file, &g)
+	case "json":
+		err = readJSON(file, &g)
+	case "dot":
+		err = readDOT(file, &g)
+	default:
+		err = fmt.Errorf("невідомий формат %q (доступні: %s)", format, strings.Join(graphFormats, ", "))
+	}
+	if err != nil {
+		return Graph{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+func parseEdge(v, u string) (int, int, error) {
+	from, err1 := strconv.Atoi(strings.TrimSpace(v))
+	to, err2 := strconv.Atoi(strings.TrimSpace(u))
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("некоректне ребро %q -> %q", v, u)
+	}
+	return from, to, nil
+}
+
+// readEdgeList reads one "v u" edge per line. Blank lines and lines starting
+// with '#' are skipped.
+func readEdgeList(r io.Reader, g *Graph) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return fmt.Errorf("рядок %d: очікується ребро \"v u\"", line)
+		}
+		v, u, err := parseEdge(fields[0], fields[1])
+		if err != nil {
+			return fmt.Errorf("рядок %d: %w", line, err)
+		}
+		g.addEdge(v, u)
+	}
+	return scanner.Err()
+}
+
+// readCSV reads "source,target" rows. A first row that is not an edge is
+// taken as a header.
+func readCSV(r io.Reader, g *Graph) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		v, u, err := parseEdge(record[0], record[1])
+		if err != nil {
+			if first {
+				continue
+			}
+			// Comments, blank lines and quoted newlines make records and
+			// lines differ, so ask the reader where the row starts.
+			line, _ := reader.FieldPos(0)
+			return fmt.Errorf("рядок %d: %w", line, err)
+		}
+		g.addEdge(v, u)
+	}
+}
+
+// readJSON accepts either an edge list, {"edges": [[1, 2], [2, 3]]}, or an
+// adjacency list, {"adjacency": {"1": [2], "2": [3]}}.
+func readJSON(r io.Reader, g *Graph) error {
+	var data struct {
+		Edges     [][2]int         `json:"edges"`
+		Adjacency map[string][]int `json:"adjacency"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return err
+	}
+	if data.Edges == nil && data.Adjacency == nil {
+		return errors.New(`очікується поле "edges" або "adjacency"`)
+	}
+	for _, e := range data.Edges {
+		g.addEdge(e[0], e[1])
+	}
+	for vStr, neighbours := range data.Adjacency {
+		v, err := strconv.Atoi(vStr)
+		if err != nil {
+			return fmt.Errorf("некоректна вершина %q", vStr)
+		}
+		for _, u := range neighbours {
+			g.addEdge(v, u)
+		}
+	}
+	return nil
+}
+
+// dotToken is a token of the DOT language: a node name or other ID, which
+// may have been quoted, or an operator or punctuation mark.
+type dotToken struct {
+	text string
+	id   bool
+}
+
+// dotTokens splits DOT source into tokens, dropping comments. Quoted strings
+// are read as a whole, so brackets, semicolons and comment markers inside
+// them have no special meaning.
+func dotTokens(text string) ([]dotToken, error) {
+	var tokens []dotToken
+	lineStart := true
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\n':
+			lineStart = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#' && lineStart:
+			i = lineEnd(text, i)
+			continue
+		case strings.HasPrefix(text[i:], "//"):
+			i = lineEnd(text, i)
+			continue
+		case strings.HasPrefix(text[i:], "/*"):
+			n := strings.Index(text[i+2:], "*/")
+			if n == -1 {
+				return nil, errors.New("незакритий коментар")
+			}
+			i += 2 + n + 2
+			continue
+		}
+		lineStart = false
+
+		switch {
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(text) && text[j] != '"'; j++ {
+				if text[j] == '\\' && j+1 < len(text) && text[j+1] == '"' {
+					j++
+				}
+				b.WriteByte(text[j])
+			}
+			if j == len(text) {
+				return nil, errors.New("незакритий рядок у лапках")
+			}
+			tokens = append(tokens, dotToken{b.String(), true})
+			i = j + 1
+		case c == '<':
+			depth, j := 0, i
+			for ; j < len(text); j++ {
+				if text[j] == '<' {
+					depth++
+				} else if text[j] == '>' {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			if j == len(text) {
+				return nil, errors.New("незакритий HTML-рядок")
+			}
+			tokens = append(tokens, dotToken{text[i : j+1], true})
+			i = j + 1
+		case strings.HasPrefix(text[i:], "->") || strings.HasPrefix(text[i:], "--"):
+			tokens = append(tokens, dotToken{text[i : i+2], false})
+			i += 2
+		case strings.IndexByte("{}[];=,:", c) != -1:
+			tokens = append(tokens, dotToken{text[i : i+1], false})
+			i++
+		case isDOTIDChar(text[i:]):
+			j := i + 1
+			for j < len(text) && isDOTIDChar(text[j:]) {
+				j++
+			}
+			tokens = append(tokens, dotToken{text[i:j], true})
+			i = j
+		default:
+			tokens = append(tokens, dotToken{text[i : i+1], false})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func lineEnd(text string, i int) int {
+	if n := strings.IndexByte(text[i:], '\n'); n != -1 {
+		return i + n
+	}
+	return len(text)
+}
+
+// isDOTIDChar reports whether rest starts with a character that continues an
+// unquoted ID. A '-' does so unless it starts an edge operator.
+func isDOTIDChar(rest string) bool {
+	c := rest[0]
+	if c == '-' {
+		return !strings.HasPrefix(rest, "->") && !strings.HasPrefix(rest, "--")
+	}
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// readDOT reads the edges of a Graphviz digraph. Chains such as a -> b -> c
+// are split into single edges, and an edge to or from a group such as
+// {b c} or a subgraph connects every node in it; in an undirected graph every
+// a -- b edge is added in both directions. Node names must be vertex numbers,
+// optionally quoted. Attributes, ports and node statements are ignored.
+func readDOT(r io.Reader, g *Graph) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	tokens, err := dotTokens(string(data))
+	if err != nil {
+		return err
+	}
+	p := &dotParser{tokens: tokens, g: g}
+	return p.graph()
+}
+
+// dotParser reads the statements of a DOT graph from its tokens and adds
+// the edges it finds to g.
+type dotParser struct {
+	tokens   []dotToken
+	pos      int
+	g        *Graph
+	directed bool
+	op       string
+}
+
+func (p *dotParser) peek() dotToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return dotToken{}
+}
+
+// at reports whether the next token is the punctuation mark or operator s.
+func (p *dotParser) at(s string) bool {
+	t := p.peek()
+	return !t.id && t.text == s
+}
+
+// atKeyword reports whether the next token is the unquoted keyword s.
+func (p *dotParser) atKeyword(s string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].id && strings.EqualFold(p.tokens[p.pos].text, s)
+}
+
+func (p *dotParser) graph() error {
+	if p.atKeyword("strict") {
+		p.pos++
+	}
+	switch {
+	case p.atKeyword("digraph"):
+		p.directed, p.op = true, "->"
+	case p.atKeyword("graph"):
+		p.op = "--"
+	default:
+		return errors.New("очікується граф у фігурних дужках")
+	}
+	p.pos++
+	if p.peek().id {
+		p.pos++
+	}
+	if !p.at("{") {
+		return errors.New("очікується граф у фігурних дужках")
+	}
+	p.pos++
+	if _, err := p.statements(); err != nil {
+		return err
+	}
+	if !p.at("}") || p.pos != len(p.tokens)-1 {
+		return errors.New("очікується граф у фігурних дужках")
+	}
+	return nil
+}
+
+// statements reads statements up to a closing brace, which it leaves in
+// place, and returns every node they mention.
+func (p *dotParser) statements() ([]string, error) {
+	var nodes []string
+	for p.pos < len(p.tokens) && !p.at("}") {
+		if p.at(";") || p.at(",") {
+			p.pos++
+			continue
+		}
+		stmtNodes, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, stmtNodes...)
+	}
+	return nodes, nil
+}
+
+func (p *dotParser) statement() ([]string, error) {
+	if p.atKeyword("graph") || p.atKeyword("node") || p.atKeyword("edge") {
+		p.pos++
+		return nil, p.skipAttributes()
+	}
+	if p.peek().id && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == (dotToken{text: "="}) {
+		p.pos += 3
+		return nil, nil
+	}
+
+	from, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	nodes := from
+	for p.at("->") || p.at("--") {
+		if !p.at(p.op) {
+			return nil, fmt.Errorf("ребро %s у графі, де ребра позначаються %s", p.peek().text, p.op)
+		}
+		p.pos++
+		to, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range from {
+			for _, u := range to {
+				if err := p.addEdge(v, u); err != nil {
+					return nil, err
+				}
+			}
+		}
+		nodes = append(nodes, to...)
+		from = to
+	}
+	return nodes, p.skipAttributes()
+}
+
+// operand reads a node, with its port if any, or a group of statements in
+// braces, optionally named as a subgraph, and returns the nodes in it.
+func (p *dotParser) operand() ([]string, error) {
+	if p.atKeyword("subgraph") {
+		p.pos++
+		if p.peek().id {
+			p.pos++
+		}
+		if !p.at("{") {
+			return nil, errors.New("очікується { після subgraph")
+		}
+	}
+	if p.at("{") {
+		p.pos++
+		nodes, err := p.statements()
+		if err != nil {
+			return nil, err
+		}
+		if !p.at("}") {
+			return nil, errors.New("незакрита фігурна дужка")
+		}
+		p.pos++
+		return nodes, nil
+	}
+
+	t := p.peek()
+	if !t.id {
+		if p.pos == len(p.tokens) {
+			return nil, errors.New("неочікуваний кінець графа")
+		}
+		return nil, fmt.Errorf("очікується вершина, а не %q", t.text)
+	}
+	p.pos++
+	for i := 0; i < 2 && p.at(":"); i++ {
+		p.pos++
+		if !p.peek().id {
+			return nil, errors.New("очікується назва порту після :")
+		}
+		p.pos++
+	}
+	return []string{t.text}, nil
+}
+
+// skipAttributes skips any attribute lists, such as [color=red][label="x"].
+func (p *dotParser) skipAttributes() error {
+	for p.at("[") {
+		for p.pos++; !p.at("]"); p.pos++ {
+			if p.pos >= len(p.tokens) {
+				return errors.New("незакритий список атрибутів")
+			}
+		}
+		p.pos++
+	}
+	return nil
+}
+
+func (p *dotParser) addEdge(from, to string) error {
+	v, u, err := parseEdge(from, to)
+	if err != nil {
+		return err
+	}
+	p.g.addEdge(v, u)
+	if !p.directed {
+		p.g.addEdge(u, v)
+	}
+	return nil
+}
+
+// readSetsFile reads sets in the same "name v1 v2 ..." form as the prompt,
+// one per line, or as a JSON object such as {"ui": [1, 2], "core": [3]}.
+func readSetsFile(path string) (map[string][]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sets := make(map[string][]int)
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		if err := json.NewDecoder(file).Decode(&sets); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return sets, nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16*1024*1024) // a large set is one long line
+	for line := 1; scanner.Scan(); line++ {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) == 0 || strings.HasPrefix(parts[0], "#") {
+			continue
+		}
+		var vertices []int
+		for _, vStr := range parts[1:] {
+			v, err := strconv.Atoi(vStr)
+			if err != nil {
+				return nil, fmt.Errorf("%s: рядок %d: некоректна вершина %q", path, line, vStr)
+			}
+			vertices = append(vertices, v)
+		}
+		sets[parts[0]] = vertices
+	}
+	return sets, scanner.Err()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDOTTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []dotToken
+	}{
+		{
+			name: "edge chain",
+			text: "1->2 -- -3",
+			want: []dotToken{{"1", true}, {"->", false}, {"2", true}, {"--", false}, {"-3", true}},
+		},
+		{
+			name: "quoted brackets and semicolons",
+			text: `[label="x]y;z"]`,
+			want: []dotToken{{"[", false}, {"label", true}, {"=", false}, {"x]y;z", true}, {"]", false}},
+		},
+		{
+			name: "escaped quote",
+			text: `"a\"b"`,
+			want: []dotToken{{`a"b`, true}},
+		},
+		{
+			name: "comment markers inside quotes",
+			text: `[URL="http://x" note="/* no */ # no"]`,
+			want: []dotToken{{"[", false}, {"URL", true}, {"=", false}, {"http://x", true},
+				{"note", true}, {"=", false}, {"/* no */ # no", true}, {"]", false}},
+		},
+		{
+			name: "comments",
+			text: "# preprocessor line\n1 // line comment -> 9\n/* block\n-> 8 */ 2 # not at line start",
+			want: []dotToken{{"1", true}, {"2", true}, {"#", false}, {"not", true}, {"at", true}, {"line", true}, {"start", true}},
+		},
+		{
+			name: "html label",
+			text: "label=<<b>x</b>>",
+			want: []dotToken{{"label", true}, {"=", false}, {"<<b>x</b>>", true}},
+		},
+		{
+			name: "punctuation",
+			text: "subgraph s{a:n;b,c}",
+			want: []dotToken{{"subgraph", true}, {"s", true}, {"{", false}, {"a", true}, {":", false}, {"n", true},
+				{";", false}, {"b", true}, {",", false}, {"c", true}, {"}", false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dotTokens(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dotTokens(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDOTTokensErrors(t *testing.T) {
+	for _, text := range []string{`"open`, "/* open", "<open"} {
+		if _, err := dotTokens(text); err == nil {
+			t.Errorf("dotTokens(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+func TestReadDOT(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want map[int][]int
+	}{
+		{
+			name: "chain",
+			text: "digraph { 1 -> 2 -> 3 }",
+			want: map[int][]int{1: {2}, 2: {3}},
+		},
+		{
+			name: "attributes with quoted brackets",
+			text: "digraph G {\n  1 -> 2 [URL=\"http://x\"];\n  2 -> 3 [color=red];\n  3 -> 4 [label=\"x]y\"]\n}",
+			want: map[int][]int{1: {2}, 2: {3}, 3: {4}},
+		},
+		{
+			name: "statements without semicolons",
+			text: "digraph {\n1 -> 2\n2 -> 3\n}",
+			want: map[int][]int{1: {2}, 2: {3}},
+		},
+		{
+			name: "node, edge and graph attributes",
+			text: `strict digraph "deps" { rankdir=LR; node [shape=box]; edge [color="]"]; 1 [label="a -> b"]; "1" -> "2" }`,
+			want: map[int][]int{1: {2}},
+		},
+		{
+			name: "undirected",
+			text: "graph { 1 -- 2 -- 3 }",
+			want: map[int][]int{1: {2}, 2: {1, 3}, 3: {2}},
+		},
+		{
+			name: "edge groups",
+			text: "digraph { 1 -> {2 3}; {4; 5} -> 6 }",
+			want: map[int][]int{1: {2, 3}, 4: {6}, 5: {6}},
+		},
+		{
+			name: "subgraphs",
+			text: "digraph { subgraph cluster_a { 1 -> 2 } subgraph { 3 } -> 4; 5 -> subgraph s { 6 7 } }",
+			want: map[int][]int{1: {2}, 3: {4}, 5: {6, 7}},
+		},
+		{
+			name: "ports",
+			text: "digraph { 1:out -> 2:in:n }",
+			want: map[int][]int{1: {2}},
+		},
+		{
+			name: "comments",
+			text: "digraph {\n# 7 -> 8\n1 -> 2 // 9 -> 10\n/* 3 -> 4 */ }",
+			want: map[int][]int{1: {2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Graph{adjacencyList: make(map[int][]int)}
+			if err := readDOT(strings.NewReader(tt.text), &g); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g.adjacencyList, tt.want) {
+				t.Errorf("edges = %v, want %v", g.adjacencyList, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadDOTErrors(t *testing.T) {
+	for _, text := range []string{
+		"1 -> 2",
+		"digraph { 1 -> 2",
+		"digraph { 1 -> }",
+		"digraph { -> 1 }",
+		"digraph { 1 -- 2 }",
+		`digraph { 1 -> "x" }`,
+		"digraph { 1 -> 2 [color=red }",
+		"digraph { 1 -> {2 3 }",
+		"digraph { } 1 -> 2",
+	} {
+		g := Graph{adjacencyList: make(map[int][]int)}
+		if err := readDOT(strings.NewReader(text), &g); err == nil {
+			t.Errorf("readDOT(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+func TestReadCSVErrorLine(t *testing.T) {
+	text := "source,target\n# comment\n\n1,2\n\"3\n\",4\n5,x\n"
+	g := Graph{adjacencyList: make(map[int][]int)}
+	err := readCSV(strings.NewReader(text), &g)
+	if err == nil || !strings.HasPrefix(err.Error(), "рядок 7:") {
+		t.Errorf("readCSV error = %v, want it on line 7", err)
+	}
+}
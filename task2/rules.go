@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ruleKind int
+
+const (
+	mayDepend ruleKind = iota
+	mustNotDepend
+	maxWeight
+)
+
+type rule struct {
+	line     int
+	kind     ruleKind
+	from, to string
+	limit    int
+}
+
+var rulePatterns = []struct {
+	kind ruleKind
+	re   *regexp.Regexp
+}{
+	{mayDepend, regexp.MustCompile(`^(\S+)\s+may\s+depend\s+on\s+(\S+)$`)},
+	{mustNotDepend, regexp.MustCompile(`^(\S+)\s+must\s+not\s+depend\s+on\s+(\S+)$`)},
+	{maxWeight, regexp.MustCompile(`^max\s+weight\s+from\s+(\S+)\s+to\s+(\S+)\s+is\s+(\d+)$`)},
+}
+
+// readRulesFile reads one rule per line, skipping empty lines and # comments:
+//
+//	ui may depend on core
+//	core must not depend on ui
+//	max weight from A to B is 10
+//
+// Once a set has a "may depend on" rule it may depend only on the sets its
+// "may" rules name.
+func readRulesFile(path string) ([]rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []rule
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("рядок %d: %v", n, err)
+		}
+		r.line = n
+		rules = append(rules, r)
+	}
+	return rules, scanner.Err()
+}
+
+func parseRule(line string) (rule, error) {
+	for _, p := range rulePatterns {
+		match := p.re.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		r := rule{kind: p.kind, from: match[1], to: match[2]}
+		if p.kind == maxWeight {
+			limit, err := strconv.Atoi(match[3])
+			if err != nil {
+				return rule{}, fmt.Errorf("невірна вага %q", match[3])
+			}
+			r.limit = limit
+		}
+		return r, nil
+	}
+	return rule{}, fmt.Errorf("невідоме правило %q", line)
+}
+
+func (r rule) String() string {
+	switch r.kind {
+	case mayDepend:
+		return fmt.Sprintf("%s may depend on %s", r.from, r.to)
+	case mustNotDepend:
+		return fmt.Sprintf("%s must not depend on %s", r.from, r.to)
+	}
+	return fmt.Sprintf("max weight from %s to %s is %d", r.from, r.to, r.limit)
+}
+
+type violation struct {
+	rule     rule
+	from, to string
+	weight   int
+}
+
+// checkRules returns the dependencies of the set graph that break a rule.
+// A dependency outside the allowed sets is reported against the first "may"
+// rule of its set. Rules that name an unknown set are an error.
+func checkRules(rules []rule, weightedGraph map[string]map[string]int) ([]violation, error) {
+	allowed := make(map[string]map[string]bool)
+	first := make(map[string]rule)
+	for _, r := range rules {
+		for _, name := range []string{r.from, r.to} {
+			if _, ok := weightedGraph[name]; !ok {
+				return nil, fmt.Errorf("рядок %d: невідома множина %q", r.line, name)
+			}
+		}
+		if r.kind == mayDepend {
+			if allowed[r.from] == nil {
+				allowed[r.from] = make(map[string]bool)
+				first[r.from] = r
+			}
+			allowed[r.from][r.to] = true
+		}
+	}
+
+	var violations []violation
+	for _, name := range sortedNames(allowed) {
+		for _, to := range sortedNames(weightedGraph[name]) {
+			if !allowed[name][to] {
+				violations = append(violations, violation{first[name], name, to, weightedGraph[name][to]})
+			}
+		}
+	}
+	for _, r := range rules {
+		weight := weightedGraph[r.from][r.to]
+		if r.kind == mustNotDepend && weight > 0 || r.kind == maxWeight && weight > r.limit {
+			violations = append(violations, violation{r, r.from, r.to, weight})
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].rule.line < violations[j].rule.line })
+	return violations, nil
+}
+
+// violatingEdges lists the edges of g that lead from a vertex of set from to
+// a vertex of set to, that is the edges behind their dependency index.
+func violatingEdges(g Graph, sets map[string][]int, from, to string) [][2]int {
+	target := make(map[int]bool)
+	for _, u := range sets[to] {
+		target[u] = true
+	}
+	sources := append([]int(nil), sets[from]...)
+	sort.Ints(sources)
+
+	var edges [][2]int
+	for i, v := range sources {
+		if i > 0 && v == sources[i-1] {
+			continue
+		}
+		neighbours := append([]int(nil), g.adjacencyList[v]...)
+		sort.Ints(neighbours)
+		for _, u := range neighbours {
+			if target[u] {
+				edges = append(edges, [2]int{v, u})
+			}
+		}
+	}
+	return edges
+}
+
+func writeViolations(w io.Writer, violations []violation, g Graph, sets map[string][]int) {
+	if len(violations) == 0 {
+		fmt.Fprintln(w, "\nУсі правила виконано.")
+		return
+	}
+	fmt.Fprintln(w, "\nПорушення правил:")
+	for _, v := range violations {
+		if v.rule.kind == mayDepend {
+			fmt.Fprintf(w, "  рядок %d: %s може залежати лише від дозволених множин, але залежить від %s [вага: %d]\n", v.rule.line, v.from, v.to, v.weight)
+		} else {
+			fmt.Fprintf(w, "  рядок %d: %s [вага: %d]\n", v.rule.line, v.rule, v.weight)
+		}
+		for _, e := range violatingEdges(g, sets, v.from, v.to) {
+			fmt.Fprintf(w, "    %d -> %d\n", e[0], e[1])
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
@@ -36,22 +37,7 @@ func readGraph() Graph {
 	return g
 }
 
-func dependencyIndex(g Graph, setM, setN []int) int {
-	index := 0
-	for _, v := range setM {
-		for _, u := range g.adjacencyList[v] {
-			for _, n := range setN {
-				if u == n {
-					index++
-				}
-			}
-		}
-	}
-	return index
-}
-
-func main() {
-	graph := readGraph()
+func readSets() map[string][]int {
 	sets := make(map[string][]int)
 	var setsCount int
 	fmt.Print("Введіть кількість множин: ")
@@ -70,24 +56,102 @@ func main() {
 		}
 		sets[name] = vertices
 	}
+	return sets
+}
+
+func main() {
+	graphFile := flag.String("graph", "", "файл графа замість введення з клавіатури")
+	format := flag.String("format", "", "формат файлу графа: "+strings.Join(graphFormats, ", ")+" (за замовчуванням визначається за розширенням)")
+	setsFile := flag.String("sets", "", "файл множин, по одній на рядок: назва v1 v2 ... (або JSON)")
+	export := flag.String("export", "text", "формат зваженого графа: "+strings.Join(exportFormats, ", "))
+	output := flag.String("o", "", "файл для зваженого графа замість стандартного виводу")
+	workers := flag.Int("workers", 1, "кількість горутин для обчислення індексів залежності")
+	analyze := flag.Bool("analyze", false, "знайти сильно зв'язні компоненти, цикли та шари множин")
+	maxCycles := flag.Int("max-cycles", 100, "найбільша кількість циклів у звіті")
+	discover := flag.String("discover", "", "запропонувати множини за графом замість -sets: "+strings.Join(discoverMethods, ", "))
+	rulesFile := flag.String("rules", "", "файл правил залежностей; за порушення програма завершується з ненульовим кодом")
+	flag.Parse()
+
+	if *maxCycles < 1 {
+		fmt.Println("Помилка: -max-cycles має бути не менше 1")
+		os.Exit(1)
+	}
+
+	var rules []rule
+	if *rulesFile != "" {
+		var err error
+		if rules, err = readRulesFile(*rulesFile); err != nil {
+			fmt.Println("Помилка читання правил:", err)
+			os.Exit(1)
+		}
+	}
 
-	weightedGraph := make(map[string]map[string]int)
-	for nameM, setM := range sets {
-		weightedGraph[nameM] = make(map[string]int)
-		for nameN, setN := range sets {
-			if nameM != nameN {
-				index := dependencyIndex(graph, setM, setN)
-				if index > 0 {
-					weightedGraph[nameM][nameN] = index
-				}
-			}
+	var graph Graph
+	if *graphFile != "" {
+		var err error
+		if graph, err = readGraphFile(*graphFile, *format); err != nil {
+			fmt.Println("Помилка читання графа:", err)
+			os.Exit(1)
 		}
+	} else {
+		graph = readGraph()
 	}
 
-	fmt.Println("\nЗважений граф:")
-	for nameM, neighbors := range weightedGraph {
-		for nameN, weight := range neighbors {
-			fmt.Printf("%s -> %s [вага: %d]\n", nameM, nameN, weight)
+	var sets map[string][]int
+	if *discover != "" {
+		var q float64
+		var err error
+		if sets, q, err = discoverSets(graph, *discover); err != nil {
+			fmt.Println("Помилка пошуку множин:", err)
+			os.Exit(1)
+		}
+		// Only the text export can share standard output with the proposal.
+		proposal := os.Stdout
+		if *export != "text" && *output == "" {
+			proposal = os.Stderr
+		}
+		fmt.Fprintf(proposal, "Запропоновані множини (%s, модулярність %.4f):\n", *discover, q)
+		writeSets(proposal, sets)
+	} else if *setsFile != "" {
+		var err error
+		if sets, err = readSetsFile(*setsFile); err != nil {
+			fmt.Println("Помилка читання множин:", err)
+			os.Exit(1)
+		}
+	} else {
+		sets = readSets()
+	}
+
+	weightedGraph := buildWeightedGraph(graph, sets, *workers)
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Println("Помилка запису:", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := writeWeightedGraph(out, *export, weightedGraph, sets); err != nil {
+		fmt.Println("Помилка запису:", err)
+		os.Exit(1)
+	}
+
+	if *analyze {
+		writeAnalysis(os.Stdout, weightedGraph, *maxCycles)
+	}
+
+	if *rulesFile != "" {
+		violations, err := checkRules(rules, weightedGraph)
+		if err != nil {
+			fmt.Println("Помилка перевірки правил:", err)
+			os.Exit(1)
+		}
+		writeViolations(os.Stdout, violations, graph, sets)
+		if len(violations) > 0 {
+			os.Exit(1)
 		}
 	}
 }
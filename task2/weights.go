@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// membershipIndex maps every vertex to the indices of the sets it belongs
+// to, once per occurrence, so a vertex listed twice in a set counts twice
+// just like in a pairwise scan of the sets.
+func membershipIndex(names []string, sets map[string][]int) map[int][]int {
+	members := make(map[int][]int)
+	for i, name := range names {
+		for _, v := range sets[name] {
+			members[v] = append(members[v], i)
+		}
+	}
+	return members
+}
+
+// buildWeightedGraph computes the dependency index of every ordered pair of
+// sets in one pass over the edges: an edge v -> u adds one to every pair
+// (set of v, set of u). With more than one worker the vertices are split
+// between goroutines that each count into their own map of the pairs they
+// meet, and the maps are summed at the end, so memory grows with the pairs
+// that occur rather than with the square of the number of sets.
+func buildWeightedGraph(g Graph, sets map[string][]int, workers int) map[string]map[string]int {
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	members := membershipIndex(names, sets)
+
+	vertices := make([]int, 0, len(members))
+	for v := range members {
+		vertices = append(vertices, v)
+	}
+
+	workers = max(1, min(workers, len(vertices)))
+	counts := make([]map[[2]int]int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		counts[w] = make(map[[2]int]int)
+		wg.Add(1)
+		go func(count map[[2]int]int, part []int) {
+			defer wg.Done()
+			for _, v := range part {
+				for _, u := range g.adjacencyList[v] {
+					for _, m := range members[v] {
+						for _, n := range members[u] {
+							if m != n {
+								count[[2]int{m, n}]++
+							}
+						}
+					}
+				}
+			}
+		}(counts[w], vertices[w*len(vertices)/workers:(w+1)*len(vertices)/workers])
+	}
+	wg.Wait()
+
+	weightedGraph := make(map[string]map[string]int, len(names))
+	for _, name := range names {
+		weightedGraph[name] = make(map[string]int)
+	}
+	for _, count := range counts {
+		for pair, index := range count {
+			weightedGraph[names[pair[0]]][names[pair[1]]] += index
+		}
+	}
+	return weightedGraph
+}